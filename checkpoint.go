@@ -0,0 +1,75 @@
+package state_machine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A named snapshot of the machine taken by Checkpoint()
+//
+// HistoryPosition is the number of transitions recorded in the history
+// when the checkpoint was taken (always 0 when the history is disabled).
+type Checkpoint struct {
+	Name            string
+	State           StateID
+	HistoryPosition int
+}
+
+// Checkpoint() captures the current state under the given name
+//
+// Taking a checkpoint with an existing name overwrites it.
+func (sm *StateMachine) Checkpoint(name string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.checkpoints == nil {
+		sm.checkpoints = map[string]Checkpoint{}
+	}
+	sm.checkpoints[name] = Checkpoint{
+		Name:            name,
+		State:           sm.state,
+		HistoryPosition: sm.history.total,
+	}
+}
+
+// RestoreCheckpoint() puts the machine back in the state captured by the named checkpoint
+//
+// Restoring is a pure state reset: no state function runs and the transition
+// rules don't apply. The history is not rewound, instead a synthetic record
+// with the checkpoint name is appended to it.
+func (sm *StateMachine) RestoreCheckpoint(name string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	cp, ok := sm.checkpoints[name]
+	if !ok {
+		return nameError(sm.spec.Name, fmt.Errorf("unknown checkpoint %q", name))
+	}
+
+	sm.recordTransition(TransitionRecord{
+		From:      sm.state,
+		To:        cp.State,
+		External:  true,
+		Timestamp: sm.spec.clock().Now(),
+		Restored:  name,
+	})
+	sm.setState(cp.State)
+	return nil
+}
+
+// Checkpoints() returns the checkpoints sorted by name
+func (sm *StateMachine) Checkpoints() []Checkpoint {
+	result := make([]Checkpoint, 0, len(sm.checkpoints))
+	for _, cp := range sm.checkpoints {
+		result = append(result, cp)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// DeleteCheckpoint() removes the named checkpoint and reports whether it existed
+func (sm *StateMachine) DeleteCheckpoint(name string) bool {
+	_, ok := sm.checkpoints[name]
+	delete(sm.checkpoints, name)
+	return ok
+}
@@ -0,0 +1,95 @@
+package state_machine
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checkpoint Tests", func() {
+	var sm *StateMachine
+
+	BeforeEach(func() {
+		spec := getStayingSpec()
+		spec.HistoryLimit = 10
+		var err error
+		sm, err = NewStateMachine(spec)
+		Ω(err).Should(BeNil())
+	})
+
+	transitions := func(states ...StateID) {
+		for _, s := range states {
+			_, err := sm.Transition(s)
+			Ω(err).Should(BeNil())
+		}
+	}
+
+	It("should restore the checkpoint state without running the state func", func() {
+		transitions(CREATE)
+		sm.Checkpoint("created")
+		transitions(FAIL)
+		sm.spec.StateFuncMap[CREATE] = func() StateID {
+			Fail("the state func of CREATE shouldn't run")
+			return CREATE
+		}
+
+		Ω(sm.RestoreCheckpoint("created")).Should(Succeed())
+		Ω(sm.CurrentState()).Should(Equal(CREATE))
+		Ω(sm.Done()).Should(BeFalse())
+	})
+
+	It("should append a restored record to the history", func() {
+		transitions(CREATE)
+		sm.Checkpoint("created")
+		transitions(FAIL)
+		Ω(sm.RestoreCheckpoint("created")).Should(Succeed())
+
+		history := sm.History()
+		Ω(history).Should(HaveLen(3))
+		Ω(history[2].From).Should(Equal(FAIL))
+		Ω(history[2].To).Should(Equal(CREATE))
+		Ω(history[2].Restored).Should(Equal("created"))
+		Ω(history[1].Restored).Should(BeEmpty())
+	})
+
+	It("should fail to restore an unknown checkpoint", func() {
+		err := sm.RestoreCheckpoint("nope")
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Error()).Should(Equal(`unknown checkpoint "nope"`))
+		Ω(sm.CurrentState()).Should(Equal(INIT))
+	})
+
+	It("should overwrite checkpoints with the same name", func() {
+		sm.Checkpoint("cp")
+		transitions(CREATE, RUN)
+		sm.Checkpoint("cp")
+		transitions(DONE)
+		Ω(sm.RestoreCheckpoint("cp")).Should(Succeed())
+		Ω(sm.CurrentState()).Should(Equal(RUN))
+	})
+
+	It("should list and delete checkpoints", func() {
+		sm.Checkpoint("start")
+		transitions(CREATE)
+		sm.Checkpoint("created")
+
+		Ω(sm.Checkpoints()).Should(Equal([]Checkpoint{
+			{Name: "created", State: CREATE, HistoryPosition: 1},
+			{Name: "start", State: INIT, HistoryPosition: 0},
+		}))
+
+		Ω(sm.DeleteCheckpoint("start")).Should(BeTrue())
+		Ω(sm.DeleteCheckpoint("start")).Should(BeFalse())
+		Ω(sm.Checkpoints()).Should(HaveLen(1))
+	})
+
+	It("should copy checkpoints to clones and clear them on Reset()", func() {
+		sm.Checkpoint("start")
+		clone := sm.Clone()
+		clone.Checkpoint("other")
+		Ω(sm.Checkpoints()).Should(HaveLen(1))
+
+		Ω(sm.Reset()).Should(Succeed())
+		Ω(sm.Checkpoints()).Should(BeEmpty())
+		Ω(clone.Checkpoints()).Should(HaveLen(2))
+	})
+})
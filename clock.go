@@ -0,0 +1,25 @@
+package state_machine
+
+import "time"
+
+// The source of time of the machine
+//
+// Specs may set their own Clock (typically a fake one in tests).
+// Machines whose spec has no Clock use the wall clock.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// clock() returns the clock of the spec, defaulting to the wall clock
+func (sms *StateMachineSpec) clock() Clock {
+	if sms.Clock == nil {
+		return realClock{}
+	}
+	return sms.Clock
+}
@@ -0,0 +1,176 @@
+// orderflow drives a batch of orders through an order-processing state machine
+//
+// Every order gets its own machine. A failing payment routes the order to FAILED,
+// and a SIGTERM stops the intake of new orders through ShutdownAll() while the
+// order being processed runs to completion.
+//
+// Usage:
+//
+//	orderflow [-orders N] [-fail-charge ID] [-sigterm-after ID]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	sm "github.com/the-gigi/state-machine"
+)
+
+const (
+	NEW sm.StateID = iota
+	VALIDATE
+	CHARGE
+	SHIP
+	DONE
+	FAILED
+)
+
+var stateNames = map[sm.StateID]string{
+	NEW:      "NEW",
+	VALIDATE: "VALIDATE",
+	CHARGE:   "CHARGE",
+	SHIP:     "SHIP",
+	DONE:     "DONE",
+	FAILED:   "FAILED",
+}
+
+// order is the unit of work; its handlers record the trace of states they ran in
+type order struct {
+	id         int
+	failCharge bool
+	trace      []string
+}
+
+func (o *order) handler(state sm.StateID, next func() sm.StateID) sm.StateFunc {
+	return func() sm.StateID {
+		o.trace = append(o.trace, stateNames[state])
+		return next()
+	}
+}
+
+func (o *order) newSpec() *sm.StateMachineSpec {
+	stay := func(s sm.StateID) func() sm.StateID {
+		return func() sm.StateID { return s }
+	}
+	charge := func() sm.StateID {
+		if o.failCharge {
+			return FAILED
+		}
+		return SHIP
+	}
+
+	return &sm.StateMachineSpec{
+		InitialState: NEW,
+		FinalStates:  sm.StateSet{DONE: true, FAILED: true},
+		StateFuncMap: sm.StateFuncMap{
+			NEW:      o.handler(NEW, stay(VALIDATE)),
+			VALIDATE: o.handler(VALIDATE, stay(CHARGE)),
+			CHARGE:   o.handler(CHARGE, charge),
+			SHIP:     o.handler(SHIP, stay(DONE)),
+			DONE:     o.handler(DONE, stay(DONE)),
+			FAILED:   o.handler(FAILED, stay(FAILED)),
+		},
+		ValidTransitions: map[sm.StateID]sm.StateSet{
+			NEW:      {VALIDATE: true, FAILED: true},
+			VALIDATE: {CHARGE: true, FAILED: true},
+			CHARGE:   {SHIP: true, FAILED: true},
+			SHIP:     {DONE: true, FAILED: true},
+		},
+	}
+}
+
+// process() executes the order's machine until it reaches a final state
+func (o *order) process() (sm.StateID, error) {
+	machine, err := sm.NewStateMachine(o.newSpec())
+	if err != nil {
+		return NEW, err
+	}
+
+	state := NEW
+	for state != DONE && state != FAILED {
+		state, err = machine.Execute()
+		if err != nil {
+			return state, err
+		}
+	}
+	return state, nil
+}
+
+// intake accepts orders until it is shut down
+type intake struct {
+	once    sync.Once
+	stopped chan struct{}
+}
+
+func (i *intake) Shutdown(ctx context.Context) error {
+	i.once.Do(func() { close(i.stopped) })
+	return nil
+}
+
+func (i *intake) open() bool {
+	select {
+	case <-i.stopped:
+		return false
+	default:
+		return true
+	}
+}
+
+func main() {
+	orders := flag.Int("orders", 3, "number of orders to process")
+	failCharge := flag.Int("fail-charge", 0, "id of an order whose payment fails")
+	sigtermAfter := flag.Int("sigterm-after", 0, "send SIGTERM to ourselves after processing this order")
+	flag.Parse()
+
+	in := &intake{stopped: make(chan struct{})}
+	if err := sm.RegisterShutdowner("intake", sm.StopIntake, in); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := sm.ShutdownAll(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}()
+
+	exitCode := 0
+	for id := 1; id <= *orders; id++ {
+		if !in.open() {
+			fmt.Printf("order %d: skipped (shutting down)\n", id)
+			continue
+		}
+
+		o := &order{id: id, failCharge: id == *failCharge}
+		state, err := o.process()
+		if err != nil {
+			fmt.Printf("order %d: error in %s: %v\n", id, stateNames[state], err)
+			exitCode = 1
+			continue
+		}
+		fmt.Printf("order %d: %s [%s]\n", id, stateNames[state], strings.Join(o.trace, " "))
+
+		if id == *sigtermAfter {
+			p, _ := os.FindProcess(os.Getpid())
+			if err := p.Signal(syscall.SIGTERM); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			// Wait until the intake is shut down so the simulation is deterministic
+			<-in.stopped
+		}
+	}
+	os.Exit(exitCode)
+}
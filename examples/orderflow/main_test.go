@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os/exec"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+// run() starts the built orderflow binary and waits for it to exit
+func run(args ...string) *gexec.Session {
+	session, err := gexec.Start(exec.Command(binaryPath, args...), GinkgoWriter, GinkgoWriter)
+	Ω(err).Should(BeNil())
+	Eventually(session, 10*time.Second).Should(gexec.Exit())
+	return session
+}
+
+var _ = Describe("Orderflow Integration Tests", func() {
+	It("should drive every order to DONE", func() {
+		session := run("-orders", "2")
+		Ω(session.ExitCode()).Should(Equal(0))
+		Ω(session).Should(gbytes.Say(`order 1: DONE \[NEW VALIDATE CHARGE SHIP\]`))
+		Ω(session).Should(gbytes.Say(`order 2: DONE \[NEW VALIDATE CHARGE SHIP\]`))
+	})
+
+	It("should route a failed payment to FAILED without affecting other orders", func() {
+		session := run("-orders", "3", "-fail-charge", "2")
+		Ω(session.ExitCode()).Should(Equal(0))
+		Ω(session).Should(gbytes.Say(`order 1: DONE`))
+		Ω(session).Should(gbytes.Say(`order 2: FAILED \[NEW VALIDATE CHARGE FAILED\]`))
+		Ω(session).Should(gbytes.Say(`order 3: DONE`))
+	})
+
+	It("should stop the intake on SIGTERM and finish the order in flight", func() {
+		session := run("-orders", "4", "-sigterm-after", "2")
+		Ω(session.ExitCode()).Should(Equal(0))
+		Ω(session).Should(gbytes.Say(`order 1: DONE`))
+		Ω(session).Should(gbytes.Say(`order 2: DONE`))
+		Ω(session).Should(gbytes.Say(`order 3: skipped \(shutting down\)`))
+		Ω(session).Should(gbytes.Say(`order 4: skipped \(shutting down\)`))
+		Ω(session.Err.Contents()).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+)
+
+var binaryPath string
+
+func TestOrderflow(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Orderflow Suite")
+}
+
+var _ = BeforeSuite(func() {
+	var err error
+	binaryPath, err = gexec.Build("github.com/the-gigi/state-machine/examples/orderflow")
+	Ω(err).Should(BeNil())
+})
+
+var _ = AfterSuite(func() {
+	gexec.CleanupBuildArtifacts()
+})
@@ -0,0 +1,14 @@
+package state_machine
+
+// Fork() returns an independent machine that starts at the current state of this one
+//
+// The fork shares the (immutable) spec with its parent, which makes forking cheap,
+// and gets its own copy of the state, history, counters and checkpoints.
+// Unlike Clone(), Fork() may be called while another goroutine drives the machine:
+// the fork point is captured atomically between transitions.
+// The fork starts with an empty handler profile.
+func (sm *StateMachine) Fork() *StateMachine {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.copyRuntime(sm.spec)
+}
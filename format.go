@@ -0,0 +1,57 @@
+package state_machine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// stateLabel() returns the label used for a state in debug output
+func (sm *StateMachine) stateLabel(state StateID) string {
+	return strconv.Itoa(int(state))
+}
+
+// String() returns a one-line summary of the machine for logging
+func (sm *StateMachine) String() string {
+	var b strings.Builder
+	b.WriteString("StateMachine{")
+	if sm.spec.Name != "" {
+		fmt.Fprintf(&b, "name: %s, ", sm.spec.Name)
+	}
+	fmt.Fprintf(&b, "state: %s, final: %t, states: %d}", sm.stateLabel(sm.state), sm.Done(), len(sm.spec.StateFuncMap))
+	return b.String()
+}
+
+// DebugString() returns the summary followed by the full transition table
+//
+// Every state gets a line with its valid targets, sorted by id.
+// The current state is marked with a '*'.
+func (sm *StateMachine) DebugString() string {
+	var b strings.Builder
+	b.WriteString(sm.String())
+	for _, s := range sm.States() {
+		b.WriteString("\n")
+		if s == sm.state {
+			b.WriteString("* ")
+		} else {
+			b.WriteString("  ")
+		}
+		b.WriteString(sm.stateLabel(s))
+		if s == sm.spec.InitialState {
+			b.WriteString(" (initial)")
+		}
+		if sm.spec.IsFinalState(s) {
+			b.WriteString(" (final)")
+			continue
+		}
+
+		targets := sm.ValidTargets(s)
+		labels := make([]string, len(targets))
+		for i, t := range targets {
+			labels[i] = sm.stateLabel(t)
+		}
+		b.WriteString(" -> ")
+		b.WriteString(strings.Join(labels, ", "))
+	}
+	return b.String()
+}
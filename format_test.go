@@ -0,0 +1,47 @@
+package state_machine
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Format Tests", func() {
+	var sm *StateMachine
+
+	BeforeEach(func() {
+		m := newMockStateMachineHandler([]StateID{INIT, CREATE, RUN, RUN, DONE})
+		var err error
+		sm, err = NewStateMachine(getDefaultSpec(m))
+		Ω(err).Should(BeNil())
+	})
+
+	It("should summarize the machine in String()", func() {
+		Ω(sm.String()).Should(Equal("StateMachine{state: 0, final: false, states: 5}"))
+		Ω(fmt.Sprint(sm)).Should(Equal(sm.String()))
+
+		sm.state = DONE
+		Ω(sm.String()).Should(Equal("StateMachine{state: 3, final: true, states: 5}"))
+	})
+
+	It("should include the name of named machines", func() {
+		sm.spec.Name = "order-42"
+		sm.state = RUN
+		Ω(sm.String()).Should(Equal("StateMachine{name: order-42, state: 2, final: false, states: 5}"))
+	})
+
+	It("should dump the transition table in DebugString()", func() {
+		sm.state = RUN
+		golden := "StateMachine{state: 2, final: false, states: 5}\n" +
+			"  0 (initial) -> 1\n" +
+			"  1 -> 2, 4\n" +
+			"* 2 -> 2, 3, 4\n" +
+			"  3 (final)\n" +
+			"  4 (final)"
+		// The output must be deterministic
+		for i := 0; i < 10; i++ {
+			Ω(sm.DebugString()).Should(Equal(golden))
+		}
+	})
+})
@@ -0,0 +1,132 @@
+package state_machine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A committed transition of the machine
+//
+// External is true when the transition was requested by Transition()
+// and false when it was the result of Execute().
+// Restored is the checkpoint name of the synthetic records added by RestoreCheckpoint().
+type TransitionRecord struct {
+	From      StateID
+	To        StateID
+	External  bool
+	Timestamp time.Time
+	Restored  string
+}
+
+// A fixed capacity ring buffer of transition records
+//
+// The zero value is an empty ring. Records are added in order and once the
+// ring is full every new record evicts the oldest one.
+type historyRing struct {
+	records []TransitionRecord
+	// The index of the oldest record (only non-zero once the ring is full)
+	start int
+	// The number of records added so far, including the evicted ones
+	total int
+}
+
+func (h *historyRing) add(record TransitionRecord, limit int) {
+	h.total++
+	if len(h.records) < limit {
+		h.records = append(h.records, record)
+		return
+	}
+	h.records[h.start] = record
+	h.start = (h.start + 1) % len(h.records)
+}
+
+// list() returns the records from the oldest to the newest
+func (h *historyRing) list() []TransitionRecord {
+	result := make([]TransitionRecord, 0, len(h.records))
+	result = append(result, h.records[h.start:]...)
+	return append(result, h.records[:h.start]...)
+}
+
+// pop() removes the newest record and returns it
+func (h *historyRing) pop() (record TransitionRecord, ok bool) {
+	if len(h.records) == 0 {
+		return
+	}
+	records := h.list()
+	record = records[len(records)-1]
+	h.records = records[:len(records)-1]
+	h.start = 0
+	h.total--
+	return record, true
+}
+
+// last() returns the newest record
+func (h *historyRing) last() (record TransitionRecord, ok bool) {
+	if len(h.records) == 0 {
+		return
+	}
+	return h.records[(h.start+len(h.records)-1)%len(h.records)], true
+}
+
+func (h *historyRing) copy() historyRing {
+	return historyRing{records: h.list(), total: h.total}
+}
+
+// recordTransition() keeps the record as the last transition and adds it to the history
+func (sm *StateMachine) recordTransition(record TransitionRecord) {
+	sm.lastTransition = record
+	sm.hasLastTransition = true
+	sm.undone = 0
+	if sm.spec.HistoryLimit > 0 {
+		sm.history.add(record, sm.spec.HistoryLimit)
+	}
+}
+
+// LastTransition() returns the last committed transition
+//
+// The ok flag is false before the first transition (and after Reset()).
+// Self-transitions are no-ops, so they don't replace the last transition.
+func (sm *StateMachine) LastTransition() (record TransitionRecord, ok bool) {
+	return sm.lastTransition, sm.hasLastTransition
+}
+
+// History() returns the recorded transitions from the oldest to the newest
+//
+// Only committed transitions are recorded: failed attempts and no-op
+// self-transitions are not. The history keeps up to HistoryLimit records,
+// dropping the oldest ones, and is always empty when HistoryLimit is 0.
+func (sm *StateMachine) History() []TransitionRecord {
+	return sm.history.list()
+}
+
+// Undo() reverts the most recent transition recorded in the history
+//
+// The machine goes back to the source state of the transition without running
+// its state function. Up to UndoDepth consecutive transitions can be reverted
+// (as long as they are still in the history). Undoing out of a final state is
+// allowed unless the spec sets ForbidUndoFromFinal.
+func (sm *StateMachine) Undo() (StateID, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.spec.UndoDepth == 0 {
+		return sm.state, nameError(sm.spec.Name, errors.New("undo is disabled"))
+	}
+	if sm.spec.ForbidUndoFromFinal && sm.Done() {
+		return sm.state, nameError(sm.spec.Name, fmt.Errorf("can't undo from final state %d", sm.state))
+	}
+	if sm.undone >= sm.spec.UndoDepth {
+		return sm.state, nameError(sm.spec.Name, fmt.Errorf("can't undo more than %d transitions", sm.spec.UndoDepth))
+	}
+
+	record, ok := sm.history.pop()
+	if !ok {
+		return sm.state, nameError(sm.spec.Name, errors.New("there is no transition to undo"))
+	}
+
+	sm.setState(record.From)
+	sm.undone++
+	sm.lastTransition, sm.hasLastTransition = sm.history.last()
+	return sm.state, nil
+}
@@ -0,0 +1,260 @@
+package state_machine
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("History Tests", func() {
+	var (
+		sm    *StateMachine
+		clock *mockClock
+	)
+
+	BeforeEach(func() {
+		clock = newMockClock()
+		spec := getStayingSpec()
+		spec.Clock = clock
+		spec.HistoryLimit = 3
+		var err error
+		sm, err = NewStateMachine(spec)
+		Ω(err).Should(BeNil())
+	})
+
+	// transitions() performs the external transitions and advances the clock a second before each one
+	transitions := func(states ...StateID) {
+		for _, s := range states {
+			clock.Advance(time.Second)
+			_, err := sm.Transition(s)
+			Ω(err).Should(BeNil())
+		}
+	}
+
+	Context("Last transition", func() {
+		It("should report no transition before the first one", func() {
+			_, ok := sm.LastTransition()
+			Ω(ok).Should(BeFalse())
+		})
+
+		It("should record external transitions", func() {
+			_, err := sm.Transition(CREATE)
+			Ω(err).Should(BeNil())
+			_, err = sm.Transition(FAIL)
+			Ω(err).Should(BeNil())
+
+			record, ok := sm.LastTransition()
+			Ω(ok).Should(BeTrue())
+			Ω(record.From).Should(Equal(CREATE))
+			Ω(record.To).Should(Equal(FAIL))
+			Ω(record.External).Should(BeTrue())
+		})
+
+		It("should record transitions performed by Execute()", func() {
+			sm.state = RUN
+			sm.spec.StateFuncMap[RUN] = func() StateID { return FAIL }
+			_, err := sm.Execute()
+			Ω(err).Should(BeNil())
+
+			record, ok := sm.LastTransition()
+			Ω(ok).Should(BeTrue())
+			Ω(record.From).Should(Equal(RUN))
+			Ω(record.To).Should(Equal(FAIL))
+			Ω(record.External).Should(BeFalse())
+		})
+
+		It("should not overwrite the record on a self-transition", func() {
+			_, err := sm.Transition(CREATE)
+			Ω(err).Should(BeNil())
+			_, err = sm.Transition(RUN)
+			Ω(err).Should(BeNil())
+			_, err = sm.Transition(RUN)
+			Ω(err).Should(BeNil())
+			_, err = sm.Execute()
+			Ω(err).Should(BeNil())
+
+			record, _ := sm.LastTransition()
+			Ω(record.From).Should(Equal(CREATE))
+			Ω(record.To).Should(Equal(RUN))
+		})
+
+		It("should not record failed transitions", func() {
+			_, err := sm.Transition(DONE)
+			Ω(err).ShouldNot(BeNil())
+			_, ok := sm.LastTransition()
+			Ω(ok).Should(BeFalse())
+		})
+
+		It("should forget the last transition on Reset()", func() {
+			sm.state = CREATE
+			_, err := sm.Transition(FAIL)
+			Ω(err).Should(BeNil())
+			Ω(sm.Reset()).Should(Succeed())
+			_, ok := sm.LastTransition()
+			Ω(ok).Should(BeFalse())
+		})
+	})
+
+	Context("Transition history", func() {
+		It("should record the transitions in order", func() {
+			start := clock.Now()
+			transitions(CREATE, RUN)
+			sm.spec.StateFuncMap[RUN] = func() StateID { return DONE }
+			clock.Advance(time.Second)
+			_, err := sm.Execute()
+			Ω(err).Should(BeNil())
+
+			Ω(sm.History()).Should(Equal([]TransitionRecord{
+				{From: INIT, To: CREATE, External: true, Timestamp: start.Add(time.Second)},
+				{From: CREATE, To: RUN, External: true, Timestamp: start.Add(2 * time.Second)},
+				{From: RUN, To: DONE, External: false, Timestamp: start.Add(3 * time.Second)},
+			}))
+		})
+
+		It("should drop the oldest records beyond the limit", func() {
+			sm.spec.ValidTransitions[RUN][CREATE] = true
+			transitions(CREATE, RUN, CREATE, RUN, DONE)
+
+			history := sm.History()
+			Ω(history).Should(HaveLen(3))
+			var path []StateID
+			for _, r := range history {
+				path = append(path, r.From)
+			}
+			Ω(path).Should(Equal([]StateID{RUN, CREATE, RUN}))
+			Ω(history[2].To).Should(Equal(DONE))
+		})
+
+		It("should not record failed transitions and self-transitions", func() {
+			transitions(CREATE, RUN, RUN)
+			_, err := sm.Transition(INIT)
+			Ω(err).ShouldNot(BeNil())
+			Ω(sm.History()).Should(HaveLen(2))
+		})
+
+		It("should be disabled when the limit is 0", func() {
+			sm.spec.HistoryLimit = 0
+			transitions(CREATE, RUN)
+			Ω(sm.History()).Should(BeEmpty())
+			_, ok := sm.LastTransition()
+			Ω(ok).Should(BeTrue())
+		})
+
+		It("should return a copy of the history", func() {
+			transitions(CREATE)
+			history := sm.History()
+			history[0].To = DONE
+			Ω(sm.History()[0].To).Should(Equal(CREATE))
+		})
+
+		It("should clear the history on Reset() and copy it to clones", func() {
+			transitions(CREATE)
+			clone := sm.Clone()
+			transitions(FAIL)
+			Ω(clone.History()).Should(HaveLen(1))
+			Ω(sm.Reset()).Should(Succeed())
+			Ω(sm.History()).Should(BeEmpty())
+			Ω(clone.History()).Should(HaveLen(1))
+		})
+
+		It("should reject a negative limit", func() {
+			spec := getStayingSpec()
+			spec.HistoryLimit = -1
+			_, err := NewStateMachine(spec)
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(Equal("the history limit can't be negative"))
+		})
+	})
+
+	Context("Undo", func() {
+		BeforeEach(func() {
+			sm.spec.UndoDepth = 2
+		})
+
+		It("should revert the last transition without running the state func", func() {
+			transitions(CREATE, RUN)
+			sm.spec.StateFuncMap[CREATE] = func() StateID {
+				Fail("the state func of CREATE shouldn't run")
+				return CREATE
+			}
+
+			state, err := sm.Undo()
+			Ω(err).Should(BeNil())
+			Ω(state).Should(Equal(CREATE))
+			Ω(sm.CurrentState()).Should(Equal(CREATE))
+			Ω(sm.History()).Should(HaveLen(1))
+			record, ok := sm.LastTransition()
+			Ω(ok).Should(BeTrue())
+			Ω(record.To).Should(Equal(CREATE))
+		})
+
+		It("should undo out of a final state", func() {
+			transitions(CREATE, FAIL)
+			Ω(sm.Done()).Should(BeTrue())
+			state, err := sm.Undo()
+			Ω(err).Should(BeNil())
+			Ω(state).Should(Equal(CREATE))
+			Ω(sm.Done()).Should(BeFalse())
+		})
+
+		It("should refuse to undo out of a final state when forbidden", func() {
+			sm.spec.ForbidUndoFromFinal = true
+			transitions(CREATE, FAIL)
+			state, err := sm.Undo()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(Equal(fmt.Sprintf("can't undo from final state %d", FAIL)))
+			Ω(state).Should(Equal(FAIL))
+		})
+
+		It("should fail when there is nothing to undo", func() {
+			_, err := sm.Undo()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(Equal("there is no transition to undo"))
+		})
+
+		It("should respect the undo depth", func() {
+			transitions(CREATE, RUN, DONE)
+			_, err := sm.Undo()
+			Ω(err).Should(BeNil())
+			_, err = sm.Undo()
+			Ω(err).Should(BeNil())
+			state, err := sm.Undo()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(Equal("can't undo more than 2 transitions"))
+			Ω(state).Should(Equal(CREATE))
+
+			// A new transition starts a new undo sequence
+			transitions(RUN)
+			state, err = sm.Undo()
+			Ω(err).Should(BeNil())
+			Ω(state).Should(Equal(CREATE))
+		})
+
+		It("should be disabled by default", func() {
+			sm.spec.UndoDepth = 0
+			transitions(CREATE)
+			_, err := sm.Undo()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(Equal("undo is disabled"))
+		})
+
+		It("should reject an undo depth beyond the history limit", func() {
+			spec := getStayingSpec()
+			spec.UndoDepth = 4
+			spec.HistoryLimit = 3
+			_, err := NewStateMachine(spec)
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(Equal("conflict UND001: UndoDepth 4 exceeds HistoryLimit 3 (undo replays the history)"))
+		})
+
+		It("should reject a negative undo depth", func() {
+			spec := getStayingSpec()
+			spec.UndoDepth = -1
+			_, err := NewStateMachine(spec)
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(Equal("the undo depth can't be negative"))
+		})
+	})
+})
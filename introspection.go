@@ -0,0 +1,58 @@
+package state_machine
+
+import "sort"
+
+// sortStates() sorts a slice of state ids in place and returns it
+func sortStates(states []StateID) []StateID {
+	sort.Slice(states, func(i, j int) bool { return states[i] < states[j] })
+	return states
+}
+
+// sortedStates() returns the members of a state set as a sorted slice
+func sortedStates(set StateSet) []StateID {
+	result := make([]StateID, 0, len(set))
+	for s, ok := range set {
+		if ok {
+			result = append(result, s)
+		}
+	}
+	return sortStates(result)
+}
+
+// States() returns all the states of the machine sorted by id
+func (sm *StateMachine) States() []StateID {
+	result := make([]StateID, 0, len(sm.spec.StateFuncMap))
+	for s := range sm.spec.StateFuncMap {
+		result = append(result, s)
+	}
+	return sortStates(result)
+}
+
+// FinalStates() returns the final states of the machine sorted by id
+func (sm *StateMachine) FinalStates() []StateID {
+	return sortedStates(sm.spec.FinalStates)
+}
+
+// Transitions() returns the valid transitions of the machine
+//
+// The result maps each source state to its sorted target states. It is a copy,
+// so modifying it doesn't affect the machine.
+func (sm *StateMachine) Transitions() map[StateID][]StateID {
+	result := make(map[StateID][]StateID, len(sm.spec.ValidTransitions))
+	for s, targets := range sm.spec.ValidTransitions {
+		result[s] = sortedStates(targets)
+	}
+	return result
+}
+
+// ValidTargets() returns the sorted states the machine may transition to from the given state
+//
+// Final states and unknown states have no targets, so the result is an empty slice
+func (sm *StateMachine) ValidTargets(state StateID) []StateID {
+	return sortedStates(sm.spec.ValidTransitions[state])
+}
+
+// NextStates() returns the sorted states the machine may transition to from its current state
+func (sm *StateMachine) NextStates() []StateID {
+	return sm.ValidTargets(sm.state)
+}
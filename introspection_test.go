@@ -0,0 +1,71 @@
+package state_machine
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Introspection Tests", func() {
+	var sm *StateMachine
+
+	BeforeEach(func() {
+		m := newMockStateMachineHandler([]StateID{INIT, CREATE, RUN, RUN, DONE})
+		var err error
+		sm, err = NewStateMachine(getDefaultSpec(m))
+		Ω(err).Should(BeNil())
+	})
+
+	It("should return the sorted states", func() {
+		Ω(sm.States()).Should(Equal([]StateID{INIT, CREATE, RUN, DONE, FAIL}))
+	})
+
+	It("should return the sorted final states", func() {
+		Ω(sm.FinalStates()).Should(Equal([]StateID{DONE, FAIL}))
+	})
+
+	It("should return the transitions with sorted targets", func() {
+		Ω(sm.Transitions()).Should(Equal(map[StateID][]StateID{
+			INIT:   {CREATE},
+			CREATE: {RUN, FAIL},
+			RUN:    {RUN, DONE, FAIL},
+		}))
+	})
+
+	It("should return copies that can't change the machine", func() {
+		states := sm.States()
+		states[0] = NO_SUCH_STATE
+		finals := sm.FinalStates()
+		finals[0] = RUN
+		transitions := sm.Transitions()
+		transitions[INIT][0] = DONE
+		transitions[DONE] = []StateID{INIT}
+
+		Ω(sm.States()[0]).Should(Equal(INIT))
+		Ω(sm.spec.IsFinalState(RUN)).Should(BeFalse())
+		Ω(sm.spec.IsFinalState(DONE)).Should(BeTrue())
+		Ω(sm.Transitions()).ShouldNot(HaveKey(DONE))
+		Ω(sm.isValidTransition(CREATE)).Should(BeTrue())
+		Ω(sm.isValidTransition(DONE)).Should(BeFalse())
+	})
+
+	It("should return the sorted valid targets of a state", func() {
+		Ω(sm.ValidTargets(INIT)).Should(Equal([]StateID{CREATE}))
+		Ω(sm.ValidTargets(RUN)).Should(Equal([]StateID{RUN, DONE, FAIL}))
+	})
+
+	It("should return no targets for final and unknown states", func() {
+		for _, s := range []StateID{DONE, FAIL, NO_SUCH_STATE} {
+			targets := sm.ValidTargets(s)
+			Ω(targets).ShouldNot(BeNil())
+			Ω(targets).Should(BeEmpty())
+		}
+	})
+
+	It("should return the targets of the current state", func() {
+		Ω(sm.NextStates()).Should(Equal([]StateID{CREATE}))
+		sm.state = CREATE
+		Ω(sm.NextStates()).Should(Equal([]StateID{RUN, FAIL}))
+		sm.state = DONE
+		Ω(sm.NextStates()).Should(BeEmpty())
+	})
+})
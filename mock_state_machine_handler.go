@@ -7,8 +7,10 @@ type mockStateMachineHandler struct {
 }
 
 // Advances the canned transition index and returns the next state
+//
+// Once the canned transitions are exhausted it keeps returning the last one
 func (m *mockStateMachineHandler) cannedTransition() StateID {
-	if m.current < len(m.cannedTransitions) {
+	if m.current < len(m.cannedTransitions)-1 {
 		m.current += 1
 	}
 	return m.cannedTransitions[m.current]
@@ -0,0 +1,35 @@
+package state_machine
+
+// The classification of a final state
+type Outcome int
+
+const (
+	OutcomeSuccess Outcome = iota + 1
+	OutcomeFailure
+	OutcomeCancelled
+)
+
+var outcomeNames = map[Outcome]string{
+	OutcomeSuccess:   "success",
+	OutcomeFailure:   "failure",
+	OutcomeCancelled: "cancelled",
+}
+
+func (o Outcome) String() string {
+	if name, ok := outcomeNames[o]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Outcome() returns the outcome of the final state the machine is in
+//
+// The ok flag is false while the machine is not done, or if its final state
+// has no entry in the spec's FinalOutcomes.
+func (sm *StateMachine) Outcome() (outcome Outcome, ok bool) {
+	if !sm.Done() {
+		return
+	}
+	outcome, ok = sm.spec.FinalOutcomes[sm.state]
+	return
+}
@@ -0,0 +1,66 @@
+package state_machine
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Outcome Tests", func() {
+	var spec *StateMachineSpec
+
+	BeforeEach(func() {
+		spec = getStayingSpec()
+		spec.FinalOutcomes = map[StateID]Outcome{DONE: OutcomeSuccess, FAIL: OutcomeFailure}
+	})
+
+	It("should report no outcome while the machine is running", func() {
+		sm, err := NewStateMachine(spec)
+		Ω(err).Should(BeNil())
+		_, ok := sm.Outcome()
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("should report the outcome of the final state", func() {
+		for final, expected := range spec.FinalOutcomes {
+			sm, err := NewStateMachineAt(spec, RUN)
+			Ω(err).Should(BeNil())
+			_, err = sm.Transition(final)
+			Ω(err).Should(BeNil())
+
+			outcome, ok := sm.Outcome()
+			Ω(ok).Should(BeTrue())
+			Ω(outcome).Should(Equal(expected))
+		}
+	})
+
+	It("should report no outcome for an unclassified final state", func() {
+		delete(spec.FinalOutcomes, FAIL)
+		sm, err := NewStateMachineAt(spec, FAIL)
+		Ω(err).Should(BeNil())
+		_, ok := sm.Outcome()
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("should reject an outcome for a state that isn't final", func() {
+		spec.FinalOutcomes[RUN] = OutcomeCancelled
+		_, err := NewStateMachine(spec)
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Error()).Should(Equal(fmt.Sprintf("state %d has an outcome but is not a final state", RUN)))
+	})
+
+	It("should reject an invalid outcome", func() {
+		spec.FinalOutcomes[DONE] = Outcome(0)
+		_, err := NewStateMachine(spec)
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Error()).Should(Equal(fmt.Sprintf("final state %d has an invalid outcome 0", DONE)))
+	})
+
+	It("should have readable outcome names", func() {
+		Ω(OutcomeSuccess.String()).Should(Equal("success"))
+		Ω(OutcomeFailure.String()).Should(Equal("failure"))
+		Ω(OutcomeCancelled.String()).Should(Equal("cancelled"))
+		Ω(Outcome(17).String()).Should(Equal("unknown"))
+	})
+})
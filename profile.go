@@ -0,0 +1,75 @@
+package state_machine
+
+import (
+	"context"
+	"runtime/pprof"
+	"strconv"
+	"time"
+)
+
+// Sampled handler timings of a single state
+//
+// Count is the number of sampled invocations and SampledTotal their total wall time.
+// Each sample stands for ProfileSampleRate invocations, so EstimatedTotal
+// extrapolates the wall time of all the invocations of the state's handler.
+type ProfileStats struct {
+	Count          int
+	SampledTotal   time.Duration
+	EstimatedTotal time.Duration
+}
+
+// runStateFunc() invokes the function of the state and samples it if profiling is enabled
+//
+// The non-sampled path costs just a counter increment and a branch.
+func (sm *StateMachine) runStateFunc(state StateID) StateID {
+	stateFunc := sm.spec.StateFuncMap[state]
+	if sm.spec.ProfileSampleRate > 0 {
+		sm.invocations++
+		if sm.invocations%uint64(sm.spec.ProfileSampleRate) == 0 {
+			return sm.runSampledStateFunc(state, stateFunc)
+		}
+	}
+	return stateFunc()
+}
+
+func (sm *StateMachine) runSampledStateFunc(state StateID, stateFunc StateFunc) (newState StateID) {
+	start := time.Now()
+	if sm.spec.ProfileLabels {
+		labels := pprof.Labels("state", strconv.Itoa(int(state)))
+		if sm.spec.Name != "" {
+			labels = pprof.Labels("state", strconv.Itoa(int(state)), "machine", sm.spec.Name)
+		}
+		pprof.Do(context.Background(), labels, func(context.Context) {
+			newState = stateFunc()
+		})
+	} else {
+		newState = stateFunc()
+	}
+	sm.recordSample(state, time.Since(start))
+	return
+}
+
+func (sm *StateMachine) recordSample(state StateID, d time.Duration) {
+	if sm.profile == nil {
+		sm.profile = map[StateID]*ProfileStats{}
+	}
+	stats := sm.profile[state]
+	if stats == nil {
+		stats = &ProfileStats{}
+		sm.profile[state] = stats
+	}
+	stats.Count++
+	stats.SampledTotal += d
+	stats.EstimatedTotal += d * time.Duration(sm.spec.ProfileSampleRate)
+}
+
+// HandlerProfile() returns a copy of the sampled handler timings by state
+//
+// States whose handler was never sampled are absent from the result.
+func (sm *StateMachine) HandlerProfile() map[StateID]ProfileStats {
+	result := make(map[StateID]ProfileStats, len(sm.profile))
+	for s, stats := range sm.profile {
+		result[s] = *stats
+	}
+	return result
+}
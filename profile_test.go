@@ -0,0 +1,147 @@
+package state_machine
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// getStayingSpec() returns the default spec where every state func returns its own state
+func getStayingSpec() *StateMachineSpec {
+	spec := getDefaultSpec(newMockStateMachineHandler([]StateID{INIT}))
+	for s := range spec.StateFuncMap {
+		var currState = s // closure state is necessary here
+		spec.StateFuncMap[s] = func() StateID {
+			return currState
+		}
+	}
+	return spec
+}
+
+var _ = Describe("Handler Profile Tests", func() {
+	var spec *StateMachineSpec
+
+	BeforeEach(func() {
+		spec = getStayingSpec()
+		spec.StateFuncMap[RUN] = func() StateID {
+			time.Sleep(time.Millisecond)
+			return RUN
+		}
+	})
+
+	// newRunningMachine() creates a machine sitting in RUN, which has a self edge
+	newRunningMachine := func() *StateMachine {
+		sm, err := NewStateMachine(spec)
+		Ω(err).Should(BeNil())
+		sm.state = RUN
+		return sm
+	}
+
+	It("should not profile when the sample rate is 0", func() {
+		sm := newRunningMachine()
+		for i := 0; i < 8; i++ {
+			_, err := sm.Execute()
+			Ω(err).Should(BeNil())
+		}
+		Ω(sm.HandlerProfile()).Should(BeEmpty())
+	})
+
+	It("should sample every Nth invocation and scale the estimate by the rate", func() {
+		spec.ProfileSampleRate = 4
+		spec.ProfileLabels = true
+		sm := newRunningMachine()
+		for i := 0; i < 16; i++ {
+			_, err := sm.Execute()
+			Ω(err).Should(BeNil())
+		}
+
+		profile := sm.HandlerProfile()
+		Ω(profile).Should(HaveLen(1))
+		stats := profile[RUN]
+		Ω(stats.Count).Should(Equal(4))
+		Ω(stats.SampledTotal).Should(BeNumerically(">=", 4*time.Millisecond))
+		Ω(stats.EstimatedTotal).Should(Equal(4 * stats.SampledTotal))
+	})
+
+	It("should attribute samples to the state whose handler ran", func() {
+		spec.ProfileSampleRate = 1
+		sm, err := NewStateMachine(spec)
+		Ω(err).Should(BeNil())
+
+		// Execute() runs INIT's handler and the transition runs CREATE's handler
+		sm.spec.StateFuncMap[INIT] = func() StateID { return CREATE }
+		_, err = sm.Execute()
+		Ω(err).Should(BeNil())
+
+		profile := sm.HandlerProfile()
+		Ω(profile[INIT].Count).Should(Equal(1))
+		Ω(profile[CREATE].Count).Should(Equal(1))
+	})
+
+	It("should return a copy of the profile", func() {
+		spec.ProfileSampleRate = 1
+		sm := newRunningMachine()
+		_, err := sm.Execute()
+		Ω(err).Should(BeNil())
+
+		profile := sm.HandlerProfile()
+		profile[RUN] = ProfileStats{Count: 100}
+		Ω(sm.HandlerProfile()[RUN].Count).Should(Equal(1))
+	})
+
+	It("should not allocate on the non-sampled path", func() {
+		spec.StateFuncMap[RUN] = func() StateID { return RUN }
+		spec.ProfileSampleRate = 1 << 30
+		sm := newRunningMachine()
+		allocs := testing.AllocsPerRun(100, func() {
+			_, _ = sm.Execute()
+		})
+		Ω(allocs).Should(BeZero())
+	})
+
+	It("should reject a negative sample rate", func() {
+		spec.ProfileSampleRate = -1
+		_, err := NewStateMachine(spec)
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Error()).Should(Equal("the profile sample rate can't be negative"))
+	})
+
+	It("should warn when labels are requested without sampling", func() {
+		spec.ProfileLabels = true
+		findings := spec.Lint()
+		Ω(findings).Should(HaveLen(1))
+		Ω(findings[0].Code).Should(Equal("PRF001"))
+		Ω(findings[0].Severity).Should(Equal(RuleWarning))
+		_, err := NewStateMachine(spec)
+		Ω(err).Should(BeNil())
+	})
+})
+
+func benchmarkExecute(b *testing.B, sampleRate int) {
+	spec := getStayingSpec()
+	spec.ProfileSampleRate = sampleRate
+	sm, err := NewStateMachine(spec)
+	if err != nil {
+		b.Fatal(err)
+	}
+	sm.state = RUN
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = sm.Execute()
+	}
+}
+
+func BenchmarkExecuteProfilingDisabled(b *testing.B) {
+	benchmarkExecute(b, 0)
+}
+
+func BenchmarkExecuteProfilingNotSampled(b *testing.B) {
+	benchmarkExecute(b, 1<<30)
+}
+
+func BenchmarkExecuteProfilingSampleEvery64(b *testing.B) {
+	benchmarkExecute(b, 64)
+}
@@ -0,0 +1,233 @@
+package state_machine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The phase a component is stopped in by ShutdownAll()
+//
+// Phases run strictly in order. All the components of a phase are stopped
+// concurrently and the next phase starts only after every one of them returned.
+type ShutdownPhase int
+
+const (
+	// Stop accepting new work (machines enter drain mode, mailboxes are closed to new sends)
+	StopIntake ShutdownPhase = iota
+	// Wait for in-flight handlers to return
+	WaitInFlight
+	// Flush persistence and outboxes
+	FlushPersistence
+	// Stop background goroutines (timers, tickers, brokers)
+	StopBackground
+)
+
+var shutdownPhaseNames = map[ShutdownPhase]string{
+	StopIntake:       "stop-intake",
+	WaitInFlight:     "wait-in-flight",
+	FlushPersistence: "flush-persistence",
+	StopBackground:   "stop-background",
+}
+
+func (p ShutdownPhase) String() string {
+	if name, ok := shutdownPhaseNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("phase-%d", int(p))
+}
+
+// A component that can be stopped by ShutdownAll()
+//
+// Shutdown() must honor the context deadline. A component that doesn't return
+// in time is abandoned and reported in the error returned by ShutdownAll().
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// Adapter that lets a plain function act as a Shutdowner
+type ShutdownerFunc func(ctx context.Context) error
+
+func (f ShutdownerFunc) Shutdown(ctx context.Context) error {
+	return f(ctx)
+}
+
+// The outcome of stopping a single component
+type ShutdownResult struct {
+	Name     string
+	Phase    ShutdownPhase
+	Duration time.Duration
+	Err      error
+}
+
+type shutdownComponent struct {
+	name       string
+	phase      ShutdownPhase
+	shutdowner Shutdowner
+}
+
+// ShutdownGroup aggregates components and stops them in phase order
+//
+// The zero value is ready to use. ShutdownAll() is idempotent: subsequent calls
+// return the error of the first call without stopping anything again.
+type ShutdownGroup struct {
+	mu         sync.Mutex
+	components []shutdownComponent
+	started    bool
+	done       chan struct{}
+	results    []ShutdownResult
+	err        error
+}
+
+// Register() adds a component to the group
+//
+// Components of the same phase are stopped concurrently. Registration fails
+// once the group started shutting down.
+func (g *ShutdownGroup) Register(name string, phase ShutdownPhase, s Shutdowner) error {
+	if s == nil {
+		return fmt.Errorf("shutdowner %s can't be nil", name)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.started {
+		return fmt.Errorf("can't register %s, shutdown already started", name)
+	}
+	g.components = append(g.components, shutdownComponent{name: name, phase: phase, shutdowner: s})
+	return nil
+}
+
+// ShutdownAll() stops all the registered components phase by phase
+//
+// The returned error joins an error for every component that failed, didn't
+// finish before the ctx deadline or was never started because the deadline
+// expired in an earlier phase. Each error carries the component's timing.
+func (g *ShutdownGroup) ShutdownAll(ctx context.Context) error {
+	g.mu.Lock()
+	if g.started {
+		done := g.done
+		g.mu.Unlock()
+		<-done
+		return g.err
+	}
+	g.started = true
+	g.done = make(chan struct{})
+	components := append([]shutdownComponent(nil), g.components...)
+	g.mu.Unlock()
+
+	results, err := shutdownPhases(ctx, components)
+
+	g.mu.Lock()
+	g.results = results
+	g.err = err
+	close(g.done)
+	g.mu.Unlock()
+	return err
+}
+
+// Results() returns the per-component outcome of the last ShutdownAll()
+//
+// The results are ordered by phase and then by registration order.
+func (g *ShutdownGroup) Results() []ShutdownResult {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]ShutdownResult(nil), g.results...)
+}
+
+func shutdownPhases(ctx context.Context, components []shutdownComponent) ([]ShutdownResult, error) {
+	// Group the components by phase, keeping the registration order inside each phase
+	sort.SliceStable(components, func(i, j int) bool {
+		return components[i].phase < components[j].phase
+	})
+
+	var results []ShutdownResult
+	var errs []error
+	for start := 0; start < len(components); {
+		end := start
+		for end < len(components) && components[end].phase == components[start].phase {
+			end++
+		}
+		phase := components[start:end]
+		start = end
+
+		// Once the deadline expired there is no point starting later phases
+		if ctx.Err() != nil {
+			for _, c := range phase {
+				err := fmt.Errorf("%s (%s) was not started: %w", c.name, c.phase, ctx.Err())
+				results = append(results, ShutdownResult{Name: c.name, Phase: c.phase, Err: err})
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		for _, r := range shutdownPhase(ctx, phase) {
+			results = append(results, r)
+			if r.Err != nil {
+				errs = append(errs, r.Err)
+			}
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// shutdownPhase() stops the components of a single phase concurrently and
+// waits until they all return or the ctx is done
+func shutdownPhase(ctx context.Context, components []shutdownComponent) []ShutdownResult {
+	type outcome struct {
+		index    int
+		duration time.Duration
+		err      error
+	}
+
+	begin := time.Now()
+	outcomes := make(chan outcome, len(components))
+	for i := range components {
+		go func(i int) {
+			err := components[i].shutdowner.Shutdown(ctx)
+			outcomes <- outcome{index: i, duration: time.Since(begin), err: err}
+		}(i)
+	}
+
+	results := make([]ShutdownResult, len(components))
+	finished := make([]bool, len(components))
+	for remaining := len(components); remaining > 0; remaining-- {
+		select {
+		case o := <-outcomes:
+			c := components[o.index]
+			finished[o.index] = true
+			results[o.index] = ShutdownResult{Name: c.name, Phase: c.phase, Duration: o.duration}
+			if o.err != nil {
+				results[o.index].Err = fmt.Errorf("%s (%s) failed after %v: %w", c.name, c.phase, o.duration, o.err)
+			}
+		case <-ctx.Done():
+			// Abandon the components that are still running
+			elapsed := time.Since(begin)
+			for i, c := range components {
+				if finished[i] {
+					continue
+				}
+				err := fmt.Errorf("%s (%s) did not finish in time after %v: %w", c.name, c.phase, elapsed, ctx.Err())
+				results[i] = ShutdownResult{Name: c.name, Phase: c.phase, Duration: elapsed, Err: err}
+			}
+			return results
+		}
+	}
+	return results
+}
+
+// The package-level group used by RegisterShutdowner() and ShutdownAll()
+var defaultShutdownGroup = &ShutdownGroup{}
+
+// RegisterShutdowner() registers a component with the package-level shutdown group
+func RegisterShutdowner(name string, phase ShutdownPhase, s Shutdowner) error {
+	return defaultShutdownGroup.Register(name, phase, s)
+}
+
+// ShutdownAll() stops every component registered with RegisterShutdowner()
+func ShutdownAll(ctx context.Context) error {
+	return defaultShutdownGroup.ShutdownAll(ctx)
+}
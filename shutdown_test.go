@@ -0,0 +1,130 @@
+package state_machine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// slowShutdowner records when it was stopped and takes `delay` to stop
+type slowShutdowner struct {
+	name  string
+	delay time.Duration
+	err   error
+	log   *shutdownLog
+}
+
+type shutdownLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *shutdownLog) add(entry string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+func (l *shutdownLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.entries...)
+}
+
+func (s *slowShutdowner) Shutdown(ctx context.Context) error {
+	select {
+	case <-time.After(s.delay):
+		s.log.add(s.name)
+		return s.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var _ = Describe("Shutdown Tests", func() {
+	var (
+		g   *ShutdownGroup
+		log *shutdownLog
+	)
+
+	BeforeEach(func() {
+		g = &ShutdownGroup{}
+		log = &shutdownLog{}
+	})
+
+	register := func(name string, phase ShutdownPhase, delay time.Duration) {
+		err := g.Register(name, phase, &slowShutdowner{name: name, delay: delay, log: log})
+		Ω(err).Should(BeNil())
+	}
+
+	It("should stop the components in phase order regardless of registration order", func() {
+		register("ticker", StopBackground, 0)
+		register("persister", FlushPersistence, 5*time.Millisecond)
+		register("runner", WaitInFlight, 10*time.Millisecond)
+		register("mailbox", StopIntake, 20*time.Millisecond)
+
+		err := g.ShutdownAll(context.Background())
+		Ω(err).Should(BeNil())
+		Ω(log.get()).Should(Equal([]string{"mailbox", "runner", "persister", "ticker"}))
+
+		results := g.Results()
+		Ω(results).Should(HaveLen(4))
+		Ω(results[0].Name).Should(Equal("mailbox"))
+		Ω(results[0].Phase).Should(Equal(StopIntake))
+		Ω(results[0].Duration).Should(BeNumerically(">=", 20*time.Millisecond))
+	})
+
+	It("should report the components that didn't finish when the deadline expires mid-flush", func() {
+		register("mailbox", StopIntake, 0)
+		register("outbox", FlushPersistence, 0)
+		register("persister", FlushPersistence, time.Second)
+		register("ticker", StopBackground, 0)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := g.ShutdownAll(ctx)
+		Ω(err).ShouldNot(BeNil())
+		Ω(errors.Is(err, context.DeadlineExceeded)).Should(BeTrue())
+		Ω(err.Error()).Should(ContainSubstring("persister (flush-persistence) did not finish in time after"))
+		Ω(err.Error()).Should(ContainSubstring("ticker (stop-background) was not started"))
+		Ω(err.Error()).ShouldNot(ContainSubstring("outbox"))
+		Ω(log.get()).Should(Equal([]string{"mailbox", "outbox"}))
+	})
+
+	It("should join the errors of failing components", func() {
+		boom := errors.New("boom")
+		Ω(g.Register("persister", FlushPersistence, &slowShutdowner{name: "persister", err: boom, log: log})).Should(Succeed())
+		register("ticker", StopBackground, 0)
+
+		err := g.ShutdownAll(context.Background())
+		Ω(errors.Is(err, boom)).Should(BeTrue())
+		Ω(err.Error()).Should(ContainSubstring("persister (flush-persistence) failed after"))
+		// A failing component doesn't prevent later phases
+		Ω(log.get()).Should(Equal([]string{"persister", "ticker"}))
+	})
+
+	It("should be idempotent", func() {
+		calls := 0
+		Ω(g.Register("counter", StopIntake, ShutdownerFunc(func(ctx context.Context) error {
+			calls++
+			return errors.New("once")
+		}))).Should(Succeed())
+
+		err1 := g.ShutdownAll(context.Background())
+		err2 := g.ShutdownAll(context.Background())
+		Ω(err1).ShouldNot(BeNil())
+		Ω(err2).Should(Equal(err1))
+		Ω(calls).Should(Equal(1))
+	})
+
+	It("should reject registration after shutdown started", func() {
+		Ω(g.ShutdownAll(context.Background())).Should(Succeed())
+		err := g.Register("late", StopIntake, ShutdownerFunc(func(ctx context.Context) error { return nil }))
+		Ω(err).ShouldNot(BeNil())
+		Ω(err.Error()).Should(Equal("can't register late, shutdown already started"))
+	})
+})
@@ -0,0 +1,48 @@
+package state_machine
+
+// copyStateSet() returns an independent copy of a state set
+func copyStateSet(set StateSet) StateSet {
+	if set == nil {
+		return nil
+	}
+	result := make(StateSet, len(set))
+	for s, v := range set {
+		result[s] = v
+	}
+	return result
+}
+
+// copy() returns a deep copy of the spec
+//
+// The maps and state sets are copied, so modifying the copy doesn't affect the
+// original and vice versa. State functions are shared, since they are immutable.
+func (sms *StateMachineSpec) copy() *StateMachineSpec {
+	result := *sms
+	result.FinalStates = copyStateSet(sms.FinalStates)
+	if sms.StateFuncMap != nil {
+		result.StateFuncMap = make(StateFuncMap, len(sms.StateFuncMap))
+		for s, f := range sms.StateFuncMap {
+			result.StateFuncMap[s] = f
+		}
+	}
+	if sms.FinalOutcomes != nil {
+		result.FinalOutcomes = make(map[StateID]Outcome, len(sms.FinalOutcomes))
+		for s, outcome := range sms.FinalOutcomes {
+			result.FinalOutcomes[s] = outcome
+		}
+	}
+	if sms.ValidTransitions != nil {
+		result.ValidTransitions = make(map[StateID]StateSet, len(sms.ValidTransitions))
+		for s, targets := range sms.ValidTransitions {
+			result.ValidTransitions[s] = copyStateSet(targets)
+		}
+	}
+	return &result
+}
+
+// Spec() returns a copy of the spec the machine was created with
+//
+// Modifying the copy doesn't affect the machine
+func (sm *StateMachine) Spec() *StateMachineSpec {
+	return sm.spec.copy()
+}
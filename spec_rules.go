@@ -0,0 +1,132 @@
+package state_machine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// How bad a spec rule violation is
+type RuleSeverity int
+
+const (
+	// The options can't work together. NewStateMachine() fails.
+	RuleConflict RuleSeverity = iota
+	// The combination is legal but suspicious. Reported by Lint() only.
+	RuleWarning
+)
+
+func (s RuleSeverity) String() string {
+	if s == RuleConflict {
+		return "conflict"
+	}
+	return "warning"
+}
+
+// A problem found by the option cross-validation pass
+//
+// Every finding carries a stable code so tooling can match on it
+// (and suppress specific warnings) without parsing the message.
+type SpecFinding struct {
+	Code     string
+	Severity RuleSeverity
+	Options  []string
+	Message  string
+}
+
+func (f *SpecFinding) Error() string {
+	return fmt.Sprintf("%s %s: %s", f.Severity, f.Code, f.Message)
+}
+
+// A single entry of the option compatibility matrix
+//
+// check() returns a message for every violation it finds in the spec
+type specRule struct {
+	code     string
+	severity RuleSeverity
+	options  []string
+	check    func(spec *StateMachineSpec) []string
+}
+
+// The spec options that interact with other options
+//
+// Every option listed here must be covered by at least one rule in specRules.
+// Adding an option to the spec means declaring its interactions below.
+var specOptions = []string{
+	"ProfileSampleRate",
+	"ProfileLabels",
+	"HistoryLimit",
+	"UndoDepth",
+	"ForbidUndoFromFinal",
+}
+
+// The option compatibility matrix
+var specRules = []specRule{
+	{
+		code:     "PRF001",
+		severity: RuleWarning,
+		options:  []string{"ProfileLabels", "ProfileSampleRate"},
+		check: func(spec *StateMachineSpec) []string {
+			if spec.ProfileLabels && spec.ProfileSampleRate == 0 {
+				return []string{"ProfileLabels has no effect when profiling is disabled (ProfileSampleRate is 0)"}
+			}
+			return nil
+		},
+	},
+	{
+		code:     "UND001",
+		severity: RuleConflict,
+		options:  []string{"UndoDepth", "HistoryLimit"},
+		check: func(spec *StateMachineSpec) []string {
+			if spec.UndoDepth > spec.HistoryLimit {
+				return []string{fmt.Sprintf("UndoDepth %d exceeds HistoryLimit %d (undo replays the history)",
+					spec.UndoDepth, spec.HistoryLimit)}
+			}
+			return nil
+		},
+	},
+	{
+		code:     "UND002",
+		severity: RuleWarning,
+		options:  []string{"ForbidUndoFromFinal", "UndoDepth"},
+		check: func(spec *StateMachineSpec) []string {
+			if spec.ForbidUndoFromFinal && spec.UndoDepth == 0 {
+				return []string{"ForbidUndoFromFinal has no effect when undo is disabled (UndoDepth is 0)"}
+			}
+			return nil
+		},
+	},
+}
+
+// checkSpecRules() evaluates the rules against the spec and returns all the findings
+func checkSpecRules(spec *StateMachineSpec, rules []specRule) []*SpecFinding {
+	var findings []*SpecFinding
+	for _, r := range rules {
+		for _, msg := range r.check(spec) {
+			findings = append(findings, &SpecFinding{
+				Code:     r.code,
+				Severity: r.severity,
+				Options:  r.options,
+				Message:  msg,
+			})
+		}
+	}
+	return findings
+}
+
+// conflictsError() joins the hard conflicts among the findings (nil if there are none)
+func conflictsError(findings []*SpecFinding) error {
+	var errs []error
+	for _, f := range findings {
+		if f.Severity == RuleConflict {
+			errs = append(errs, f)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Lint() returns every conflict and warning the option cross-validation finds
+//
+// Conflicts make NewStateMachine() fail, warnings are only reported here.
+func (sms *StateMachineSpec) Lint() []*SpecFinding {
+	return checkSpecRules(sms, specRules)
+}
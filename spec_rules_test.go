@@ -0,0 +1,79 @@
+package state_machine
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Spec Rules Tests", func() {
+	var spec *StateMachineSpec
+
+	BeforeEach(func() {
+		m := newMockStateMachineHandler([]StateID{INIT, CREATE, RUN, RUN, DONE})
+		spec = getDefaultSpec(m)
+	})
+
+	It("should cover every registered option with at least one rule", func() {
+		covered := map[string]bool{}
+		for _, r := range specRules {
+			for _, o := range r.options {
+				covered[o] = true
+			}
+		}
+		for _, o := range specOptions {
+			Ω(covered).Should(HaveKey(o), "option %s has no rule", o)
+		}
+	})
+
+	It("should have unique rule codes", func() {
+		codes := map[string]bool{}
+		for _, r := range specRules {
+			Ω(codes).ShouldNot(HaveKey(r.code))
+			codes[r.code] = true
+		}
+	})
+
+	It("should report no findings for the default spec", func() {
+		Ω(spec.Lint()).Should(BeEmpty())
+	})
+
+	It("should separate hard conflicts from warnings", func() {
+		rules := []specRule{
+			{
+				code:     "T001",
+				severity: RuleConflict,
+				options:  []string{"AllowExternalTransition"},
+				check: func(spec *StateMachineSpec) []string {
+					if spec.AllowExternalTransition {
+						return []string{"external transitions are allowed"}
+					}
+					return nil
+				},
+			},
+			{
+				code:     "T002",
+				severity: RuleWarning,
+				options:  []string{"InitialState"},
+				check: func(spec *StateMachineSpec) []string {
+					return []string{"first", "second"}
+				},
+			},
+		}
+
+		findings := checkSpecRules(spec, rules)
+		Ω(findings).Should(HaveLen(3))
+		Ω(findings[0].Code).Should(Equal("T001"))
+		Ω(findings[0].Error()).Should(Equal("conflict T001: external transitions are allowed"))
+		Ω(findings[2].Error()).Should(Equal("warning T002: second"))
+
+		err := conflictsError(findings)
+		var finding *SpecFinding
+		Ω(errors.As(err, &finding)).Should(BeTrue())
+		Ω(finding.Code).Should(Equal("T001"))
+
+		spec.AllowExternalTransition = false
+		Ω(conflictsError(checkSpecRules(spec, rules))).Should(BeNil())
+	})
+})
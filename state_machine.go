@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"reflect"
 	"runtime"
+	"sync"
+	"time"
 )
 
 type State struct {
@@ -35,16 +37,64 @@ type StateFuncMap = map[StateID]StateFunc
 // It starts in the initial state, enforces valid transitions
 // until it reaches a final state (if any) and then it stays there.
 type StateMachine struct {
+	// Guards the state and the transition bookkeeping, so Fork() can snapshot
+	// a machine while another goroutine drives it
+	mu sync.RWMutex
+
 	state StateID
 	spec  *StateMachineSpec
+
+	// Handler sampling profiler bookkeeping (see profile.go)
+	invocations uint64
+	profile     map[StateID]*ProfileStats
+
+	// The last committed transition (valid only when hasLastTransition is set)
+	lastTransition    TransitionRecord
+	hasLastTransition bool
+	// The bounded transition history (see HistoryLimit)
+	history historyRing
+	// The number of consecutive transitions reverted by Undo()
+	undone int
+	// Named checkpoints (see checkpoint.go)
+	checkpoints map[string]Checkpoint
+
+	// When the current state was entered and the time spent in previous visits of each state
+	enteredAt time.Time
+	durations map[StateID]time.Duration
+
+	// Transition counters
+	transitions     int
+	selfTransitions int
+	edgeCounts      map[StateID]map[StateID]int
 }
 
 type StateMachineSpec struct {
+	// Optional name that identifies the machine in errors and logs
+	Name string
+
 	InitialState            StateID
 	FinalStates             StateSet
 	StateFuncMap            StateFuncMap
 	ValidTransitions        map[StateID]StateSet
 	AllowExternalTransition bool
+
+	// Optional classification of the final states
+	FinalOutcomes map[StateID]Outcome
+
+	// The source of time for the machine's bookkeeping (defaults to the wall clock)
+	Clock Clock
+
+	// The maximal number of transitions kept in the history (0 disables the history)
+	HistoryLimit int
+	// The maximal number of consecutive transitions Undo() can revert (0 disables undo)
+	UndoDepth int
+	// Forbid Undo() when the machine is in a final state
+	ForbidUndoFromFinal bool
+
+	// Sample 1 in ProfileSampleRate handler invocations (0 disables profiling)
+	ProfileSampleRate int
+	// Set pprof labels around sampled handler invocations
+	ProfileLabels bool
 }
 
 func (sms *StateMachineSpec) IsFinalState(state StateID) bool {
@@ -53,33 +103,95 @@ func (sms *StateMachineSpec) IsFinalState(state StateID) bool {
 
 // NewStateMachine() takes a StateMachineSpec, verifies it
 // and creates a new StateMachine using the spec
+//
+// The machine keeps its own deep copy of the spec, so modifying the spec
+// after the machine was created has no effect on the machine.
 func NewStateMachine(spec *StateMachineSpec) (*StateMachine, error) {
 	if spec == nil {
 		return nil, errors.New("the StateMachine spec can't be empty")
 	}
 
+	// Validate and use a private copy, so the machine's behavior is frozen at validation time
+	spec = spec.copy()
+
+	if err := spec.validate(); err != nil {
+		return nil, nameError(spec.Name, err)
+	}
+
+	// Return a StateMachine instance with the spec, and set the `state` field to the initial state
+	return &StateMachine{
+		spec:      spec,
+		state:     spec.InitialState,
+		enteredAt: spec.clock().Now(),
+	}, nil
+}
+
+// NewStateMachineAt() is like NewStateMachine() but the machine starts in the given state
+//
+// It is designed for restoring machines from persisted state. The state may be
+// any state of the spec, including a final state.
+func NewStateMachineAt(spec *StateMachineSpec, state StateID) (*StateMachine, error) {
+	sm, err := NewStateMachine(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if sm.spec.StateFuncMap[state] == nil {
+		return nil, nameError(sm.spec.Name, fmt.Errorf("can't start in state %d, it is missing from the state map", state))
+	}
+
+	sm.state = state
+	return sm, nil
+}
+
+// nameError() prefixes the error with the machine name
+//
+// Errors of unnamed machines are returned as is
+func nameError(name string, err error) error {
+	if name == "" || err == nil {
+		return err
+	}
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+// validate() verifies the spec is consistent
+//
+// It returns an error describing the first problem found
+func (sms *StateMachineSpec) validate() error {
+	spec := sms
+
 	// Make sure there is a handler function for each state
 	for s, stateFunc := range spec.StateFuncMap {
 		if stateFunc == nil {
-			return nil, fmt.Errorf("missing function for state %d", s)
+			return fmt.Errorf("missing function for state %d", s)
 		}
 	}
 
 	// Make sure there the initial state is in the state map
 	if spec.StateFuncMap[spec.InitialState] == nil {
-		return nil, errors.New("the initial state is missing from the state map")
+		return errors.New("the initial state is missing from the state map")
 	}
 
 	// Make sure all the final states are in the state map
 	for k := range spec.FinalStates {
 		if spec.StateFuncMap[k] == nil {
-			return nil, fmt.Errorf("the final state %d is missing from the state map", k)
+			return fmt.Errorf("the final state %d is missing from the state map", k)
 		}
 	}
 
 	// Make sure the initial state is not one of the final states
 	if spec.IsFinalState(spec.InitialState) {
-		return nil, fmt.Errorf("the initial state can't be a final state")
+		return fmt.Errorf("the initial state can't be a final state")
+	}
+
+	// Make sure only final states have outcomes
+	for s, outcome := range spec.FinalOutcomes {
+		if !spec.IsFinalState(s) {
+			return fmt.Errorf("state %d has an outcome but is not a final state", s)
+		}
+		if _, ok := outcomeNames[outcome]; !ok {
+			return fmt.Errorf("final state %d has an invalid outcome %d", s, outcome)
+		}
 	}
 
 	var reachableStates = StateSet{spec.InitialState: true}
@@ -87,18 +199,18 @@ func NewStateMachine(spec *StateMachineSpec) (*StateMachine, error) {
 	for k, v := range spec.ValidTransitions {
 		// Make sure there are no transitions from a final state to any state
 		if spec.IsFinalState(k) {
-			return nil, fmt.Errorf("can't transition from a final state %d", k)
+			return fmt.Errorf("can't transition from a final state %d", k)
 		}
 
 		// Make sure the source state is in the state map
 		if spec.StateFuncMap[k] == nil {
-			return nil, fmt.Errorf("source state %d is missing from state map", k)
+			return fmt.Errorf("source state %d is missing from state map", k)
 		}
 
 		// Make sure all the destination states are in the state map + keep track of reachable states
 		for s := range v {
 			if spec.StateFuncMap[s] == nil {
-				return nil, fmt.Errorf("target state %d is missing from state map", s)
+				return fmt.Errorf("target state %d is missing from state map", s)
 			}
 			reachableStates[s] = true
 		}
@@ -107,7 +219,7 @@ func NewStateMachine(spec *StateMachineSpec) (*StateMachine, error) {
 	// Make sure all states are reachable
 	for i := range spec.StateFuncMap {
 		if !reachableStates[StateID(i)] {
-			return nil, fmt.Errorf("state %d is unreachable", i)
+			return fmt.Errorf("state %d is unreachable", i)
 		}
 	}
 
@@ -120,42 +232,176 @@ func NewStateMachine(spec *StateMachineSpec) (*StateMachine, error) {
 
 		targets := spec.ValidTransitions[s]
 		if len(targets) == 0 {
-			return nil, fmt.Errorf("there are no transitions from state %d", s)
+			return fmt.Errorf("there are no transitions from state %d", s)
 		}
 	}
 
-	// Return a StateMachine instance with the spec, and set the `state` field to the initial state
-	return &StateMachine{
-		spec:  spec,
-		state: spec.InitialState,
-	}, nil
+	if spec.HistoryLimit < 0 {
+		return fmt.Errorf("the history limit can't be negative")
+	}
+
+	if spec.UndoDepth < 0 {
+		return fmt.Errorf("the undo depth can't be negative")
+	}
+
+	if spec.ProfileSampleRate < 0 {
+		return fmt.Errorf("the profile sample rate can't be negative")
+	}
+
+	// Make sure the options don't conflict with each other
+	if err := conflictsError(spec.Lint()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// MustNewStateMachine() is like NewStateMachine() but panics if the spec is invalid
+//
+// It simplifies the initialization of package-level machines with static specs
+func MustNewStateMachine(spec *StateMachineSpec) *StateMachine {
+	sm, err := NewStateMachine(spec)
+	if err != nil {
+		panic("state_machine: MustNewStateMachine(): " + err.Error())
+	}
+	return sm
 }
 
 // transition() transitions the state machine to a new state and invoke its function
 //
-// If the transition is not allowed it will return an error
-func (sm *StateMachine) transition(newState StateID) (state StateID, err error) {
+// If the transition is not allowed it will return an error.
+// The external flag tells if the transition was requested by Transition() rather than by Execute().
+func (sm *StateMachine) transition(newState StateID, external bool) (state StateID, err error) {
+	sm.mu.Lock()
 	state = sm.state
 
 	// Verify the new state is a valid transition from the current state
 	if !sm.isValidTransition(newState) {
-		err = fmt.Errorf("can't transition from state %d to state %d", sm.state, newState)
+		sm.mu.Unlock()
+		err = nameError(sm.spec.Name, fmt.Errorf("can't transition from state %d to state %d", state, newState))
 		return
 	}
 
+	sm.countTransition(state, newState)
+
 	// If transitioning to the same state just return (no op)
 	if state == newState {
+		sm.mu.Unlock()
 		return
 	}
 
+	sm.recordTransition(TransitionRecord{
+		From:      state,
+		To:        newState,
+		External:  external,
+		Timestamp: sm.spec.clock().Now(),
+	})
+	sm.setState(newState)
+	sm.mu.Unlock()
+
 	// Execute the new state function and store its result as the state machine's state
-	newFunc := sm.spec.StateFuncMap[newState]
-	sm.state = newFunc()
+	newState = sm.runStateFunc(newState)
 
+	sm.mu.Lock()
+	sm.setState(newState)
 	state = sm.state
+	sm.mu.Unlock()
 	return
 }
 
+// Name() returns the name of the machine from its spec (empty for unnamed machines)
+func (sm *StateMachine) Name() string {
+	return sm.spec.Name
+}
+
+// CurrentState() returns the state the machine is in
+//
+// Before any transition it returns the initial state
+func (sm *StateMachine) CurrentState() StateID {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.state
+}
+
+// Done() returns true once the machine entered one of its final states
+//
+// A machine without final states is never done
+func (sm *StateMachine) Done() bool {
+	return sm.spec.IsFinalState(sm.state)
+}
+
+// Reset() puts the machine back in the initial state so it can be reused
+//
+// Resetting a machine that is still running (not in the initial state nor in a final state)
+// is refused, so a running job can't be wiped by accident
+func (sm *StateMachine) Reset() error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.state != sm.spec.InitialState && !sm.Done() {
+		return nameError(sm.spec.Name, fmt.Errorf("can't reset the state machine in non-final state %d", sm.state))
+	}
+
+	sm.state = sm.spec.InitialState
+	sm.hasLastTransition = false
+	sm.history = historyRing{}
+	sm.undone = 0
+	sm.checkpoints = nil
+	sm.enteredAt = sm.spec.clock().Now()
+	sm.durations = nil
+	sm.transitions = 0
+	sm.selfTransitions = 0
+	sm.edgeCounts = nil
+	return nil
+}
+
+// Clone() returns an independent copy of the machine in its current state
+//
+// The clone gets its own deep copy of the spec and of the runtime bookkeeping,
+// so driving (or modifying) one machine never affects the other.
+func (sm *StateMachine) Clone() *StateMachine {
+	clone := sm.copyRuntime(sm.spec.copy())
+	clone.invocations = sm.invocations
+	if sm.profile != nil {
+		clone.profile = make(map[StateID]*ProfileStats, len(sm.profile))
+		for s, stats := range sm.profile {
+			statsCopy := *stats
+			clone.profile[s] = &statsCopy
+		}
+	}
+	return clone
+}
+
+// copyRuntime() returns a machine with the given spec and a copy of the state and the transition bookkeeping
+func (sm *StateMachine) copyRuntime(spec *StateMachineSpec) *StateMachine {
+	clone := &StateMachine{
+		state:     sm.state,
+		spec:      spec,
+		enteredAt: sm.enteredAt,
+
+		transitions:     sm.transitions,
+		selfTransitions: sm.selfTransitions,
+		edgeCounts:      sm.EdgeCounts(),
+	}
+	if sm.durations != nil {
+		clone.durations = make(map[StateID]time.Duration, len(sm.durations))
+		for s, d := range sm.durations {
+			clone.durations[s] = d
+		}
+	}
+	clone.lastTransition = sm.lastTransition
+	clone.hasLastTransition = sm.hasLastTransition
+	clone.history = sm.history.copy()
+	clone.undone = sm.undone
+	if sm.checkpoints != nil {
+		clone.checkpoints = make(map[string]Checkpoint, len(sm.checkpoints))
+		for name, cp := range sm.checkpoints {
+			clone.checkpoints[name] = cp
+		}
+	}
+	return clone
+}
+
 func (sm *StateMachine) isValidTransition(newState StateID) bool {
 	return sm.spec.ValidTransitions[sm.state][newState]
 }
@@ -169,18 +415,25 @@ func (sm *StateMachine) isValidTransition(newState StateID) bool {
 // The state machine must be configured to allow external transition (disabled by default)
 func (sm *StateMachine) Transition(newState StateID) (StateID, error) {
 	if !sm.spec.AllowExternalTransition {
-		return sm.state, errors.New("external transition is forbidden")
+		return sm.state, nameError(sm.spec.Name, errors.New("external transition is forbidden"))
 	}
 
-	return sm.transition(newState)
+	return sm.transition(newState, true)
+}
+
+// CanTransition() reports whether Transition() to the target state would be allowed
+//
+// It applies the same rules as Transition() but never invokes a state function
+// or changes the state of the machine.
+func (sm *StateMachine) CanTransition(newState StateID) bool {
+	return sm.spec.AllowExternalTransition && sm.isValidTransition(newState)
 }
 
 // Execute() runs the current state function and transitions to the state it returned
 //
 // The return values are the result of the transition.
 func (sm *StateMachine) Execute() (StateID, error) {
-	stateFunc := sm.spec.StateFuncMap[sm.state]
-	newState := stateFunc()
-	return sm.transition(newState)
+	newState := sm.runStateFunc(sm.state)
+	return sm.transition(newState, false)
 
 }
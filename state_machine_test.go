@@ -62,11 +62,224 @@ var _ = Describe("StateMachine Tests", func() {
 			sm, err := NewStateMachine(spec)
 			Ω(err).Should(BeNil())
 			Ω(sm).ShouldNot(BeNil())
-			Ω(sm.spec).Should(Equal(spec))
+			Ω(sm.spec).ShouldNot(BeIdenticalTo(spec))
+			Ω(sm.spec.InitialState).Should(Equal(spec.InitialState))
+			Ω(sm.spec.FinalStates).Should(Equal(spec.FinalStates))
+			Ω(sm.spec.ValidTransitions).Should(Equal(spec.ValidTransitions))
+			Ω(sm.spec.StateFuncMap).Should(HaveLen(len(spec.StateFuncMap)))
 			Ω(sm.state).Should(Equal(spec.InitialState))
 		})
 	})
 
+	Context("Creating state machines that must be valid (using MustNewStateMachine())", func() {
+		It("should return the state machine when the spec is valid", func() {
+			sm := MustNewStateMachine(spec)
+			Ω(sm).ShouldNot(BeNil())
+			Ω(sm.CurrentState()).Should(Equal(INIT))
+		})
+
+		It("should panic with the validation error when the spec is invalid", func() {
+			spec.ValidTransitions[INIT] = StateSet{}
+			errString := fmt.Sprintf("state_machine: MustNewStateMachine(): state %d is unreachable", CREATE)
+			defer func() {
+				Ω(recover()).Should(Equal(errString))
+			}()
+			MustNewStateMachine(spec)
+			Fail("MustNewStateMachine() didn't panic")
+		})
+	})
+
+	Context("Creating state machines in a given state (using NewStateMachineAt())", func() {
+		It("should start in the requested state", func() {
+			sm, err := NewStateMachineAt(spec, RUN)
+			Ω(err).Should(BeNil())
+			Ω(sm.CurrentState()).Should(Equal(RUN))
+			Ω(sm.Done()).Should(BeFalse())
+
+			// The machine continues from there: RUN's func returns CREATE which isn't a valid target
+			_, err = sm.Execute()
+			Ω(err).ShouldNot(BeNil())
+			Ω(sm.CurrentState()).Should(Equal(RUN))
+		})
+
+		It("should allow starting in a final state", func() {
+			sm, err := NewStateMachineAt(spec, FAIL)
+			Ω(err).Should(BeNil())
+			Ω(sm.CurrentState()).Should(Equal(FAIL))
+			Ω(sm.Done()).Should(BeTrue())
+		})
+
+		It("should fail for a state that is not in the state map", func() {
+			sm, err := NewStateMachineAt(spec, NO_SUCH_STATE)
+			Ω(sm).Should(BeNil())
+			Ω(err).ShouldNot(BeNil())
+			errString := fmt.Sprintf("can't start in state %d, it is missing from the state map", NO_SUCH_STATE)
+			Ω(err.Error()).Should(Equal(errString))
+		})
+
+		It("should still validate the spec", func() {
+			spec.InitialState = FAIL
+			_, err := NewStateMachineAt(spec, RUN)
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(Equal("the initial state can't be a final state"))
+		})
+	})
+
+	Context("Cloning a state machine", func() {
+		var sm *StateMachine
+		BeforeEach(func() {
+			var err error
+			sm, err = NewStateMachine(spec)
+			Ω(err).Should(BeNil())
+			// Make every state func stay in its state
+			for s := range sm.spec.StateFuncMap {
+				var currState = s // closure state is necessary here
+				sm.spec.StateFuncMap[s] = func() StateID {
+					return currState
+				}
+			}
+		})
+
+		It("should clone the current state", func() {
+			_, err := sm.Transition(CREATE)
+			Ω(err).Should(BeNil())
+			clone := sm.Clone()
+			Ω(clone).ShouldNot(BeIdenticalTo(sm))
+			Ω(clone.CurrentState()).Should(Equal(CREATE))
+			Ω(clone.spec).ShouldNot(BeIdenticalTo(sm.spec))
+		})
+
+		It("should diverge cleanly when the clone and the original take different branches", func() {
+			_, err := sm.Transition(CREATE)
+			Ω(err).Should(BeNil())
+			clone := sm.Clone()
+
+			// The original fails, the clone runs to completion
+			_, err = sm.Transition(FAIL)
+			Ω(err).Should(BeNil())
+			_, err = clone.Transition(RUN)
+			Ω(err).Should(BeNil())
+			_, err = clone.Transition(DONE)
+			Ω(err).Should(BeNil())
+
+			Ω(sm.CurrentState()).Should(Equal(FAIL))
+			Ω(clone.CurrentState()).Should(Equal(DONE))
+		})
+
+		It("should not share the spec with the clone", func() {
+			clone := sm.Clone()
+			called := false
+			clone.spec.StateFuncMap[CREATE] = func() StateID {
+				called = true
+				return CREATE
+			}
+			clone.spec.ValidTransitions[INIT][FAIL] = true
+			Ω(sm.isValidTransition(FAIL)).Should(BeFalse())
+
+			_, err := sm.Transition(CREATE)
+			Ω(err).Should(BeNil())
+			Ω(called).Should(BeFalse())
+		})
+
+		It("should copy the runtime bookkeeping", func() {
+			sm.spec.ProfileSampleRate = 1
+			sm.state = RUN
+			_, err := sm.Execute()
+			Ω(err).Should(BeNil())
+
+			clone := sm.Clone()
+			Ω(clone.HandlerProfile()).Should(Equal(sm.HandlerProfile()))
+			_, err = clone.Execute()
+			Ω(err).Should(BeNil())
+			Ω(clone.HandlerProfile()[RUN].Count).Should(Equal(2))
+			Ω(sm.HandlerProfile()[RUN].Count).Should(Equal(1))
+		})
+	})
+
+	Context("Named state machines", func() {
+		BeforeEach(func() {
+			spec.Name = "order-42"
+		})
+
+		It("should return the name of the machine", func() {
+			sm, err := NewStateMachine(spec)
+			Ω(err).Should(BeNil())
+			Ω(sm.Name()).Should(Equal("order-42"))
+		})
+
+		It("should prefix validation errors with the name", func() {
+			spec.ValidTransitions[INIT] = StateSet{}
+			_, err := NewStateMachine(spec)
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(Equal(fmt.Sprintf("order-42: state %d is unreachable", CREATE)))
+		})
+
+		It("should prefix transition errors with the name", func() {
+			sm, err := NewStateMachine(spec)
+			Ω(err).Should(BeNil())
+			_, err = sm.Transition(DONE)
+			Ω(err.Error()).Should(Equal(fmt.Sprintf("order-42: can't transition from state %d to state %d", INIT, DONE)))
+
+			sm.spec.AllowExternalTransition = false
+			_, err = sm.Transition(CREATE)
+			Ω(err.Error()).Should(Equal("order-42: external transition is forbidden"))
+
+			sm.state = RUN
+			err = sm.Reset()
+			Ω(err.Error()).Should(Equal(fmt.Sprintf("order-42: can't reset the state machine in non-final state %d", RUN)))
+		})
+
+		It("should have an empty name when unnamed", func() {
+			spec.Name = ""
+			sm, err := NewStateMachine(spec)
+			Ω(err).Should(BeNil())
+			Ω(sm.Name()).Should(BeEmpty())
+		})
+	})
+
+	Context("Isolation from the caller's spec", func() {
+		It("should not be affected by mutating the spec after creation", func() {
+			sm, err := NewStateMachine(spec)
+			Ω(err).Should(BeNil())
+
+			// Mutate every collection of the original spec in ways validation would reject
+			spec.ValidTransitions[INIT][DONE] = true
+			delete(spec.ValidTransitions[INIT], CREATE)
+			spec.ValidTransitions[DONE] = StateSet{INIT: true}
+			spec.FinalStates[RUN] = true
+			spec.StateFuncMap[CREATE] = nil
+			spec.AllowExternalTransition = false
+
+			Ω(sm.isValidTransition(CREATE)).Should(BeTrue())
+			Ω(sm.isValidTransition(DONE)).Should(BeFalse())
+			Ω(sm.spec.IsFinalState(RUN)).Should(BeFalse())
+
+			// The machine still runs to completion with the validated spec
+			for !sm.Done() {
+				_, err = sm.Execute()
+				Ω(err).Should(BeNil())
+			}
+			Ω(sm.CurrentState()).Should(Equal(DONE))
+		})
+
+		It("should return a copy of the spec from Spec()", func() {
+			sm, err := NewStateMachine(spec)
+			Ω(err).Should(BeNil())
+
+			copied := sm.Spec()
+			Ω(copied).ShouldNot(BeIdenticalTo(sm.spec))
+			Ω(copied.ValidTransitions).Should(Equal(spec.ValidTransitions))
+			Ω(copied.FinalStates).Should(Equal(spec.FinalStates))
+
+			copied.ValidTransitions[INIT][DONE] = true
+			copied.FinalStates[RUN] = true
+			delete(copied.StateFuncMap, INIT)
+			Ω(sm.isValidTransition(DONE)).Should(BeFalse())
+			Ω(sm.spec.IsFinalState(RUN)).Should(BeFalse())
+			Ω(sm.spec.StateFuncMap).Should(HaveKey(INIT))
+		})
+	})
+
 	Context("Failed state machine creation", func() {
 		It("should fail when a handler function is missing for a state", func() {
 			spec.StateFuncMap[CREATE] = nil
@@ -150,7 +363,10 @@ var _ = Describe("StateMachine Tests", func() {
 		BeforeEach(func() {
 			sm, err = NewStateMachine(spec)
 			Ω(err).Should(BeNil())
-			transitionFuncs = []transitionFunc{sm.transition, sm.Transition}
+			internalTransition := func(newState StateID) (StateID, error) {
+				return sm.transition(newState, false)
+			}
+			transitionFuncs = []transitionFunc{internalTransition, sm.Transition}
 
 		})
 
@@ -295,7 +511,184 @@ var _ = Describe("StateMachine Tests", func() {
 		})
 	})
 
+	Context("Checking transitions without performing them (using CanTransition())", func() {
+		var sm *StateMachine
+		var called bool
+		BeforeEach(func() {
+			var err error
+			sm, err = NewStateMachine(spec)
+			Ω(err).Should(BeNil())
+
+			// Record any state func call
+			called = false
+			for s := range sm.spec.StateFuncMap {
+				var currState = s // closure state is necessary here
+				sm.spec.StateFuncMap[s] = func() StateID {
+					called = true
+					return currState
+				}
+			}
+		})
+
+		It("should report valid transitions without side effects", func() {
+			sm.state = CREATE
+			Ω(sm.CanTransition(RUN)).Should(BeTrue())
+			Ω(sm.CanTransition(FAIL)).Should(BeTrue())
+			Ω(sm.CanTransition(INIT)).Should(BeFalse())
+			Ω(sm.CanTransition(NO_SUCH_STATE)).Should(BeFalse())
+			Ω(sm.state).Should(Equal(CREATE))
+			Ω(called).Should(BeFalse())
+		})
+
+		It("should report self-transitions only when the self edge exists", func() {
+			sm.state = RUN
+			Ω(sm.CanTransition(RUN)).Should(BeTrue())
+			sm.state = CREATE
+			Ω(sm.CanTransition(CREATE)).Should(BeFalse())
+		})
+
+		It("should always report false from final states", func() {
+			for _, final := range []StateID{DONE, FAIL} {
+				sm.state = final
+				for s := range sm.spec.StateFuncMap {
+					Ω(sm.CanTransition(s)).Should(BeFalse())
+				}
+			}
+		})
+
+		It("should report false when external transitions are disallowed", func() {
+			sm.spec.AllowExternalTransition = false
+			Ω(sm.CanTransition(CREATE)).Should(BeFalse())
+		})
+	})
+
 	Context("State machine execution (using the Execute() method)", func() {
 
 	})
+
+	Context("Querying the current state", func() {
+		It("should return the initial state right after creation", func() {
+			sm, err := NewStateMachine(spec)
+			Ω(err).Should(BeNil())
+			Ω(sm.CurrentState()).Should(Equal(INIT))
+		})
+
+		It("should track the state through the lifecycle until DONE", func() {
+			sm, err := NewStateMachine(spec)
+			Ω(err).Should(BeNil())
+
+			// INIT's func returns CREATE and CREATE's func returns RUN
+			newState, err := sm.Execute()
+			Ω(err).Should(BeNil())
+			Ω(sm.CurrentState()).Should(Equal(RUN))
+			Ω(sm.CurrentState()).Should(Equal(newState))
+
+			// RUN -> RUN
+			_, err = sm.Execute()
+			Ω(err).Should(BeNil())
+			Ω(sm.CurrentState()).Should(Equal(RUN))
+
+			// RUN -> DONE
+			newState, err = sm.Execute()
+			Ω(err).Should(BeNil())
+			Ω(newState).Should(Equal(DONE))
+			Ω(sm.CurrentState()).Should(Equal(DONE))
+		})
+
+		It("should not change the current state when a transition fails", func() {
+			sm, err := NewStateMachine(spec)
+			Ω(err).Should(BeNil())
+			_, err = sm.Transition(DONE)
+			Ω(err).ShouldNot(BeNil())
+			Ω(sm.CurrentState()).Should(Equal(INIT))
+		})
+	})
+
+	Context("Checking if the machine is done", func() {
+		It("should be done once a final state is reached and stay done", func() {
+			sm, err := NewStateMachine(spec)
+			Ω(err).Should(BeNil())
+			Ω(sm.Done()).Should(BeFalse())
+
+			for !sm.Done() {
+				_, err = sm.Execute()
+				Ω(err).Should(BeNil())
+			}
+			Ω(sm.CurrentState()).Should(Equal(DONE))
+			Ω(sm.Done()).Should(BeTrue())
+			Ω(sm.Done()).Should(BeTrue())
+		})
+
+		It("should be done after an external transition to a final state", func() {
+			sm, err := NewStateMachine(spec)
+			Ω(err).Should(BeNil())
+			sm.state = CREATE
+			sm.spec.StateFuncMap[FAIL] = func() StateID { return FAIL }
+			_, err = sm.Transition(FAIL)
+			Ω(err).Should(BeNil())
+			Ω(sm.Done()).Should(BeTrue())
+		})
+
+		It("should never be done when there are no final states", func() {
+			spec.FinalStates = StateSet{}
+			delete(spec.StateFuncMap, DONE)
+			delete(spec.StateFuncMap, FAIL)
+			spec.ValidTransitions = map[StateID]StateSet{
+				INIT:   {CREATE: true},
+				CREATE: {RUN: true},
+				RUN:    {INIT: true},
+			}
+			sm, err := NewStateMachine(spec)
+			Ω(err).Should(BeNil())
+			for _, s := range []StateID{INIT, CREATE, RUN} {
+				sm.state = s
+				Ω(sm.Done()).Should(BeFalse())
+			}
+		})
+	})
+
+	Context("Resetting the state machine", func() {
+		var sm *StateMachine
+		BeforeEach(func() {
+			var err error
+			sm, err = NewStateMachine(spec)
+			Ω(err).Should(BeNil())
+		})
+
+		It("should reset from DONE and run again", func() {
+			for !sm.Done() {
+				_, err := sm.Execute()
+				Ω(err).Should(BeNil())
+			}
+			Ω(sm.Reset()).Should(Succeed())
+			Ω(sm.CurrentState()).Should(Equal(INIT))
+			Ω(sm.Done()).Should(BeFalse())
+
+			m.current = 0 // rewind the canned transitions
+			for !sm.Done() {
+				_, err := sm.Execute()
+				Ω(err).Should(BeNil())
+			}
+			Ω(sm.CurrentState()).Should(Equal(DONE))
+		})
+
+		It("should reset from FAIL", func() {
+			sm.state = FAIL
+			Ω(sm.Reset()).Should(Succeed())
+			Ω(sm.CurrentState()).Should(Equal(INIT))
+		})
+
+		It("should be a no-op in the initial state", func() {
+			Ω(sm.Reset()).Should(Succeed())
+			Ω(sm.CurrentState()).Should(Equal(INIT))
+		})
+
+		It("should refuse to reset a machine in flight", func() {
+			sm.state = RUN
+			err := sm.Reset()
+			Ω(err).ShouldNot(BeNil())
+			Ω(err.Error()).Should(Equal(fmt.Sprintf("can't reset the state machine in non-final state %d", RUN)))
+			Ω(sm.CurrentState()).Should(Equal(RUN))
+		})
+	})
 })
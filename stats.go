@@ -0,0 +1,85 @@
+package state_machine
+
+import "time"
+
+// setState() makes the given state the current state and accounts for the time
+// spent in the state the machine leaves
+func (sm *StateMachine) setState(state StateID) {
+	if state == sm.state {
+		return
+	}
+
+	now := sm.spec.clock().Now()
+	if sm.durations == nil {
+		sm.durations = map[StateID]time.Duration{}
+	}
+	sm.durations[sm.state] += now.Sub(sm.enteredAt)
+	sm.state = state
+	sm.enteredAt = now
+}
+
+// TimeInCurrentState() returns how long the machine has been in its current state
+//
+// Self-transitions don't restart the clock of the current state
+func (sm *StateMachine) TimeInCurrentState() time.Duration {
+	return sm.spec.clock().Now().Sub(sm.enteredAt)
+}
+
+// StateDurations() returns the total time the machine spent in each state
+//
+// The durations accumulate across visits and include the current visit so far.
+// States the machine never entered are absent from the result.
+func (sm *StateMachine) StateDurations() map[StateID]time.Duration {
+	result := make(map[StateID]time.Duration, len(sm.durations)+1)
+	for s, d := range sm.durations {
+		result[s] = d
+	}
+	result[sm.state] += sm.TimeInCurrentState()
+	return result
+}
+
+// countTransition() updates the transition counters for a valid transition
+func (sm *StateMachine) countTransition(from StateID, to StateID) {
+	if from == to {
+		sm.selfTransitions++
+	} else {
+		sm.transitions++
+	}
+
+	if sm.edgeCounts == nil {
+		sm.edgeCounts = map[StateID]map[StateID]int{}
+	}
+	if sm.edgeCounts[from] == nil {
+		sm.edgeCounts[from] = map[StateID]int{}
+	}
+	sm.edgeCounts[from][to]++
+}
+
+// TransitionCount() returns the number of transitions that changed the state
+// since the machine was created (or reset)
+func (sm *StateMachine) TransitionCount() int {
+	return sm.transitions
+}
+
+// SelfTransitionCount() returns the number of no-op self-transitions since
+// the machine was created (or reset)
+//
+// A high count is a sign of a machine spinning in the same state.
+func (sm *StateMachine) SelfTransitionCount() int {
+	return sm.selfTransitions
+}
+
+// EdgeCounts() returns how many times each transition fired, by source and target state
+//
+// Self-transitions are counted too, so a spinning machine shows up as a hot self edge.
+// The result is a copy.
+func (sm *StateMachine) EdgeCounts() map[StateID]map[StateID]int {
+	result := make(map[StateID]map[StateID]int, len(sm.edgeCounts))
+	for from, targets := range sm.edgeCounts {
+		result[from] = make(map[StateID]int, len(targets))
+		for to, count := range targets {
+			result[from][to] = count
+		}
+	}
+	return result
+}
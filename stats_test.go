@@ -0,0 +1,146 @@
+package state_machine
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stats Tests", func() {
+	var (
+		sm    *StateMachine
+		clock *mockClock
+	)
+
+	BeforeEach(func() {
+		clock = newMockClock()
+		spec := getStayingSpec()
+		spec.Clock = clock
+		var err error
+		sm, err = NewStateMachine(spec)
+		Ω(err).Should(BeNil())
+	})
+
+	Context("Time in state", func() {
+		It("should measure the time in the current state", func() {
+			Ω(sm.TimeInCurrentState()).Should(BeZero())
+			clock.Advance(time.Minute)
+			Ω(sm.TimeInCurrentState()).Should(Equal(time.Minute))
+
+			_, err := sm.Transition(CREATE)
+			Ω(err).Should(BeNil())
+			Ω(sm.TimeInCurrentState()).Should(BeZero())
+			clock.Advance(time.Second)
+			Ω(sm.TimeInCurrentState()).Should(Equal(time.Second))
+		})
+
+		It("should not restart the clock on a self-transition", func() {
+			sm.state = CREATE
+			_, err := sm.Transition(RUN)
+			Ω(err).Should(BeNil())
+			clock.Advance(time.Minute)
+			_, err = sm.Transition(RUN)
+			Ω(err).Should(BeNil())
+			_, err = sm.Execute()
+			Ω(err).Should(BeNil())
+			clock.Advance(time.Minute)
+			Ω(sm.TimeInCurrentState()).Should(Equal(2 * time.Minute))
+		})
+
+		It("should accumulate the durations across visits", func() {
+			// RUN -> CREATE isn't a valid transition, so allow it for this test
+			sm.spec.ValidTransitions[RUN][CREATE] = true
+
+			clock.Advance(time.Second)
+			_, err := sm.Transition(CREATE)
+			Ω(err).Should(BeNil())
+			clock.Advance(2 * time.Second)
+			_, err = sm.Transition(RUN)
+			Ω(err).Should(BeNil())
+			clock.Advance(3 * time.Second)
+			_, err = sm.Transition(CREATE)
+			Ω(err).Should(BeNil())
+			clock.Advance(4 * time.Second)
+
+			Ω(sm.StateDurations()).Should(Equal(map[StateID]time.Duration{
+				INIT:   time.Second,
+				CREATE: 6 * time.Second,
+				RUN:    3 * time.Second,
+			}))
+		})
+
+		It("should return a copy of the durations", func() {
+			clock.Advance(time.Second)
+			durations := sm.StateDurations()
+			durations[INIT] = time.Hour
+			Ω(sm.StateDurations()[INIT]).Should(Equal(time.Second))
+		})
+
+		It("should clear the durations on Reset()", func() {
+			_, err := sm.Transition(CREATE)
+			Ω(err).Should(BeNil())
+			clock.Advance(time.Second)
+			_, err = sm.Transition(FAIL)
+			Ω(err).Should(BeNil())
+			Ω(sm.Reset()).Should(Succeed())
+			Ω(sm.StateDurations()).Should(Equal(map[StateID]time.Duration{INIT: 0}))
+		})
+	})
+
+	Context("Transition counters", func() {
+		It("should start with zero counts", func() {
+			Ω(sm.TransitionCount()).Should(BeZero())
+			Ω(sm.SelfTransitionCount()).Should(BeZero())
+			Ω(sm.EdgeCounts()).Should(BeEmpty())
+		})
+
+		It("should count transitions per edge and self-transitions separately", func() {
+			for _, s := range []StateID{CREATE, RUN, RUN, RUN, DONE} {
+				_, err := sm.Transition(s)
+				Ω(err).Should(BeNil())
+			}
+			_, err := sm.Transition(INIT) // invalid transitions aren't counted
+			Ω(err).ShouldNot(BeNil())
+
+			Ω(sm.TransitionCount()).Should(Equal(3))
+			Ω(sm.SelfTransitionCount()).Should(Equal(2))
+			Ω(sm.EdgeCounts()).Should(Equal(map[StateID]map[StateID]int{
+				INIT:   {CREATE: 1},
+				CREATE: {RUN: 1},
+				RUN:    {RUN: 2, DONE: 1},
+			}))
+		})
+
+		It("should return a copy of the edge counts", func() {
+			_, err := sm.Transition(CREATE)
+			Ω(err).Should(BeNil())
+			counts := sm.EdgeCounts()
+			counts[INIT][CREATE] = 100
+			counts[RUN] = map[StateID]int{DONE: 1}
+			Ω(sm.EdgeCounts()).Should(Equal(map[StateID]map[StateID]int{INIT: {CREATE: 1}}))
+		})
+
+		It("should zero the counters on Reset()", func() {
+			_, err := sm.Transition(CREATE)
+			Ω(err).Should(BeNil())
+			_, err = sm.Transition(FAIL)
+			Ω(err).Should(BeNil())
+			Ω(sm.Reset()).Should(Succeed())
+			Ω(sm.TransitionCount()).Should(BeZero())
+			Ω(sm.SelfTransitionCount()).Should(BeZero())
+			Ω(sm.EdgeCounts()).Should(BeEmpty())
+		})
+
+		It("should copy the counters to clones", func() {
+			_, err := sm.Transition(CREATE)
+			Ω(err).Should(BeNil())
+			clone := sm.Clone()
+			_, err = clone.Transition(RUN)
+			Ω(err).Should(BeNil())
+			Ω(clone.TransitionCount()).Should(Equal(2))
+			Ω(sm.TransitionCount()).Should(Equal(1))
+			Ω(sm.EdgeCounts()).ShouldNot(HaveKey(CREATE))
+		})
+	})
+})